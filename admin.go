@@ -0,0 +1,30 @@
+package main
+
+import (
+	"context"
+	"time"
+)
+
+// AdminRefresher refreshes the admins from the members of the admin user group periodically,
+// so the members added to the group can approve the requests without restart.
+type AdminRefresher struct {
+	ctx        context.Context
+	repository *Repository
+	interval   time.Duration
+}
+
+//
+func (s *AdminRefresher) Run() {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.repository.RefreshAdmins(); err != nil {
+				logger.Errorf("Failed to refresh admins: %s", err.Error())
+			}
+		}
+	}
+}
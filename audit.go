@@ -0,0 +1,219 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// audit events
+	auditEventRequested            = "requested"
+	auditEventOrganizationSelected = "organization_selected"
+	auditEventConfirmed            = "confirmed"
+	auditEventApproved             = "approved"
+	auditEventRejected             = "rejected"
+	auditEventCanceled             = "canceled"
+	auditEventExpired              = "expired"
+	auditEventExecuted             = "executed"
+	auditEventFailed               = "failed"
+
+	// maxAuditRecordSize is the upper limit of the size of an audit record in the json lines file.
+	maxAuditRecordSize = 1 << 20 // 1MB
+)
+
+// AuditStore stores the lifecycle events of the requests, the stored records are never updated nor deleted.
+type AuditStore interface {
+	Append(record AuditRecord) error
+	Query(filter AuditFilter) ([]AuditRecord, error)
+}
+
+//
+type AuditRecord struct {
+	Time         time.Time `json:"time"`
+	Event        string    `json:"event"`
+	CallbackID   string    `json:"callback_id,omitempty"`
+	Action       string    `json:"action,omitempty"`
+	Actor        User      `json:"actor"`
+	Requester    User      `json:"requester"`
+	Target       string    `json:"target,omitempty"`
+	Organization string    `json:"organization,omitempty"`
+	Reason       string    `json:"reason,omitempty"`
+	ExpireDate   string    `json:"expire_date,omitempty"`
+	Message      string    `json:"message,omitempty"`
+}
+
+// Targets returns the targets of the request, e.g. emails or screen names.
+func (r AuditRecord) Targets() []string {
+	if r.Target == "" {
+		return nil
+	}
+	return strings.Split(r.Target, ",")
+}
+
+// NewAuditRecord returns the record of the event of the request by the actor.
+func NewAuditRecord(event string, cb Callback, actor User) AuditRecord {
+	return AuditRecord{
+		Time:         time.Now(),
+		Event:        event,
+		CallbackID:   cb.ID,
+		Action:       cb.Action,
+		Actor:        actor,
+		Requester:    cb.OwnerUser,
+		Target:       cb.Value,
+		Organization: cb.Organization,
+		Reason:       cb.Reason,
+		ExpireDate:   cb.ExpireDate,
+	}
+}
+
+// AuditFilter is the condition to query the audit records, the empty fields match any records.
+type AuditFilter struct {
+	CallbackID string
+	Action     string
+	Event      string
+	UserID     string // matches the actor or the requester
+	UserName   string // matches the name of the actor or the requester
+	Target     string // matches one of the targets
+	Since      time.Time
+}
+
+// Match returns true if the record matches the all conditions.
+func (f AuditFilter) Match(r AuditRecord) bool {
+	if f.CallbackID != "" && f.CallbackID != r.CallbackID {
+		return false
+	}
+	if f.Action != "" && f.Action != r.Action {
+		return false
+	}
+	if f.Event != "" && f.Event != r.Event {
+		return false
+	}
+	if f.UserID != "" && f.UserID != r.Actor.ID && f.UserID != r.Requester.ID {
+		return false
+	}
+	if f.UserName != "" && f.UserName != r.Actor.Name && f.UserName != r.Requester.Name {
+		return false
+	}
+	if !f.Since.IsZero() && r.Time.Before(f.Since) {
+		return false
+	}
+	if f.Target != "" {
+		for _, v := range r.Targets() {
+			if strings.EqualFold(v, f.Target) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+//
+func NewAuditList() *AuditList {
+	return &AuditList{}
+}
+
+// AuditList is an in-memory AuditStore, the stored records are lost when the bot restarts.
+type AuditList struct {
+	mu      sync.Mutex
+	records []AuditRecord
+}
+
+//
+func (al *AuditList) Append(record AuditRecord) error {
+	al.mu.Lock()
+	defer al.mu.Unlock()
+	al.records = append(al.records, record)
+	return nil
+}
+
+// Query returns the records which match the filter in order of time.
+func (al *AuditList) Query(filter AuditFilter) ([]AuditRecord, error) {
+	al.mu.Lock()
+	defer al.mu.Unlock()
+	var ret []AuditRecord
+	for _, v := range al.records {
+		if filter.Match(v) {
+			ret = append(ret, v)
+		}
+	}
+	return ret, nil
+}
+
+// FileAuditStore is an AuditStore which appends the records to a json lines file.
+type FileAuditStore struct {
+	mu   sync.Mutex
+	path string
+}
+
+//
+func NewFileAuditStore(path string) (*FileAuditStore, error) {
+	// fail fast if the audit log is not writable
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, err
+	}
+	if err := f.Close(); err != nil {
+		return nil, err
+	}
+	return &FileAuditStore{
+		path: path,
+	}, nil
+}
+
+//
+func (s *FileAuditStore) Append(record AuditRecord) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f, err := os.OpenFile(s.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Query returns the records which match the filter in order of time.
+func (s *FileAuditStore) Query(filter AuditFilter) ([]AuditRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f, err := os.Open(s.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var ret []AuditRecord
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), maxAuditRecordSize)
+	for scanner.Scan() {
+		var record AuditRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return nil, err
+		}
+		if filter.Match(record) {
+			ret = append(ret, record)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileAuditStore(t *testing.T) {
+	t.Parallel()
+	dir, err := ioutil.TempDir("", "esa-account-bot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "audit.jsonl")
+
+	// the audit log can not be created in a missing directory
+	_, err = NewFileAuditStore(filepath.Join(dir, "missing", "audit.jsonl"))
+	assert.Error(t, err)
+
+	store, err := NewFileAuditStore(path)
+	assert.NoError(t, err)
+	ret, err := store.Query(AuditFilter{})
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+
+	requester := User{ID: "U0001", Name: "foo", Email: "foo@example.com"}
+	approver := User{ID: "U0002", Name: "bar", Email: "bar@example.com"}
+	invite := Callback{ID: "0001", Action: requestActionInvite, Value: "baz@example.com,qux@example.com", Organization: "Other", ExpireDate: "2020-09-30", OwnerUser: requester}
+	cleanup := Callback{ID: "0002", Action: requestActionCleanup, Value: "baz,qux", Reason: "expired", OwnerUser: approver}
+	records := []AuditRecord{
+		NewAuditRecord(auditEventRequested, invite, requester),
+		NewAuditRecord(auditEventApproved, invite, approver),
+		NewAuditRecord(auditEventRequested, cleanup, approver),
+		NewAuditRecord(auditEventExecuted, invite, approver),
+	}
+	for i := range records {
+		records[i].Time = time.Now().Truncate(time.Second).UTC()
+		assert.NoError(t, store.Append(records[i]))
+	}
+
+	// reopen the store as if the bot restarted
+	reopened, err := NewFileAuditStore(path)
+	assert.NoError(t, err)
+	tests := []struct {
+		filter AuditFilter
+		expect []AuditRecord
+	}{
+		{
+			filter: AuditFilter{},
+			expect: records,
+		},
+		{
+			filter: AuditFilter{CallbackID: "0001"},
+			expect: []AuditRecord{records[0], records[1], records[3]},
+		},
+		{
+			filter: AuditFilter{Target: "QUX@example.com"},
+			expect: []AuditRecord{records[0], records[1], records[3]},
+		},
+		{
+			filter: AuditFilter{UserID: "U0001", Event: auditEventApproved},
+			expect: []AuditRecord{records[1]},
+		},
+		{
+			filter: AuditFilter{Action: requestActionCleanup},
+			expect: []AuditRecord{records[2]},
+		},
+		{
+			filter: AuditFilter{Since: time.Now().Add(time.Hour)},
+			expect: nil,
+		},
+	}
+	for _, tt := range tests {
+		ret, err := reopened.Query(tt.filter)
+		assert.NoError(t, err)
+		assert.Equal(t, tt.expect, ret)
+	}
+}
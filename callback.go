@@ -0,0 +1,253 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CallbackStore stores the pending requests which wait for the user actions.
+type CallbackStore interface {
+	GenerateID() string
+	Set(value Callback) error
+	Get(key string) (Callback, bool)
+	List() []Callback
+	Delete(key string) error
+	Sweep() ([]Callback, error)
+}
+
+//
+type Callback struct {
+	ID           string    `json:"id"`
+	Action       string    `json:"action"`
+	Value        string    `json:"value"`
+	Organization string    `json:"organization"`
+	Reason       string    `json:"reason,omitempty"`
+	ExpireDate   string    `json:"expire_date,omitempty"`
+	Reinvite     bool      `json:"reinvite,omitempty"` // revokes the pending invitation before inviting
+	OwnerUser    User      `json:"owner_user"`
+	CreatedAt    time.Time `json:"created_at"`
+	ChannelID    string    `json:"channel_id"`
+	MessageTs    string    `json:"message_ts"`
+	Stages       []Stage   `json:"stages,omitempty"`
+	Approvers    []User    `json:"approvers,omitempty"`
+}
+
+const (
+	// request actions, which are used as the key of the approval quorum
+	requestActionInvite  = "invite"
+	requestActionDelete  = "delete"
+	requestActionCleanup = "cleanup"
+	requestActionRevoke  = "revoke"
+)
+
+// ShortID returns a human readable form of the callback id to show in slack.
+func (cb Callback) ShortID() string {
+	return ShortCallbackID(cb.ID)
+}
+
+// ShortCallbackID returns a human readable form of the specified callback id.
+func ShortCallbackID(id string) string {
+	if len(id) > shortCallbackIDLength {
+		id = id[:shortCallbackIDLength]
+	}
+	return strings.ToUpper(id)
+}
+
+const (
+	callbackIDBytes       = 16
+	shortCallbackIDLength = 8
+)
+
+// generateCallbackID returns an unique and unguessable id.
+func generateCallbackID() string {
+	b := make([]byte, callbackIDBytes)
+	if _, err := rand.Read(b); err != nil {
+		panic("failed to read random bytes: " + err.Error()) // crypto/rand does not fail on supported platforms
+	}
+	return hex.EncodeToString(b)
+}
+
+//
+func NewCallbackMap(ttl time.Duration) *CallbackMap {
+	return &CallbackMap{
+		values:  map[string]Callback{},
+		ttl:     ttl,
+		timeNow: time.Now,
+	}
+}
+
+// CallbackMap is an in-memory CallbackStore, the stored values are lost when the bot restarts.
+type CallbackMap struct {
+	mu      sync.Mutex
+	values  map[string]Callback
+	ttl     time.Duration
+	timeNow func() time.Time
+}
+
+//
+func (cm *CallbackMap) GenerateID() string {
+	return generateCallbackID()
+}
+
+//
+func (cm *CallbackMap) Set(value Callback) error {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	cm.set(value)
+	return nil
+}
+
+//
+func (cm *CallbackMap) set(value Callback) {
+	if value.CreatedAt.IsZero() {
+		value.CreatedAt = cm.timeNow()
+	}
+	value.Stages = append([]Stage(nil), value.Stages...) // not to share the stages with the caller
+	value.Approvers = append([]User(nil), value.Approvers...)
+	cm.values[value.ID] = value
+}
+
+//
+func (cm *CallbackMap) Get(key string) (Callback, bool) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	value, ok := cm.values[key]
+	if !ok || cm.expired(value) {
+		return Callback{}, false
+	}
+	value.Stages = append([]Stage(nil), value.Stages...)
+	value.Approvers = append([]User(nil), value.Approvers...)
+	return value, true
+}
+
+// List returns the all pending callbacks in order of creation.
+func (cm *CallbackMap) List() []Callback {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	ret := make([]Callback, 0, len(cm.values))
+	for _, v := range cm.values {
+		if cm.expired(v) {
+			continue
+		}
+		ret = append(ret, v)
+	}
+	sortCallbacks(ret)
+	return ret
+}
+
+// Delete removes the callback which is no longer pending, e.g. approved or canceled.
+func (cm *CallbackMap) Delete(key string) error {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	delete(cm.values, key)
+	return nil
+}
+
+// Sweep removes the expired callbacks and returns them.
+func (cm *CallbackMap) Sweep() ([]Callback, error) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	return cm.sweep(), nil
+}
+
+//
+func (cm *CallbackMap) sweep() []Callback {
+	var ret []Callback
+	for key, value := range cm.values {
+		if cm.expired(value) {
+			ret = append(ret, value)
+			delete(cm.values, key)
+		}
+	}
+	sortCallbacks(ret)
+	return ret
+}
+
+//
+func (cm *CallbackMap) expired(value Callback) bool {
+	return cm.timeNow().Sub(value.CreatedAt) > cm.ttl
+}
+
+//
+func sortCallbacks(in []Callback) {
+	sort.Slice(in, func(i, j int) bool {
+		return in[i].CreatedAt.Before(in[j].CreatedAt)
+	})
+}
+
+// FileCallbackStore is a CallbackStore which persists the values to a json file,
+// so the pending requests survive the bot restarts.
+type FileCallbackStore struct {
+	*CallbackMap
+	path string
+}
+
+//
+func NewFileCallbackStore(path string, ttl time.Duration) (*FileCallbackStore, error) {
+	s := &FileCallbackStore{
+		CallbackMap: NewCallbackMap(ttl),
+		path:        path,
+	}
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+//
+func (s *FileCallbackStore) Set(value Callback) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.set(value)
+	return s.save()
+}
+
+//
+func (s *FileCallbackStore) Delete(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.values[key]; !ok {
+		return nil
+	}
+	delete(s.values, key)
+	return s.save()
+}
+
+//
+func (s *FileCallbackStore) Sweep() ([]Callback, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ret := s.sweep()
+	if len(ret) == 0 {
+		return nil, nil
+	}
+	return ret, s.save()
+}
+
+//
+func (s *FileCallbackStore) load() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var values []Callback
+	if err := readJSONFile(s.path, &values); err != nil {
+		return err
+	}
+	for _, v := range values {
+		s.values[v.ID] = v
+	}
+	return nil
+}
+
+//
+func (s *FileCallbackStore) save() error {
+	values := make([]Callback, 0, len(s.values))
+	for _, v := range s.values {
+		values = append(values, v)
+	}
+	sortCallbacks(values)
+	return writeJSONFile(s.path, values)
+}
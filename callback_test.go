@@ -0,0 +1,75 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileCallbackStore(t *testing.T) {
+	t.Parallel()
+	dir, err := ioutil.TempDir("", "esa-account-bot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "callbacks.json")
+
+	store, err := NewFileCallbackStore(path, time.Hour)
+	assert.NoError(t, err)
+	cb := Callback{
+		ID:           store.GenerateID(),
+		Value:        "foo@example.com",
+		Organization: "Other",
+		OwnerUser:    User{ID: "U0001", Name: "foo", Email: "foo@example.com"},
+		CreatedAt:    time.Now().Truncate(time.Second).UTC(),
+	}
+	assert.NoError(t, store.Set(cb))
+
+	// reopen the store as if the bot restarted
+	reopened, err := NewFileCallbackStore(path, time.Hour)
+	assert.NoError(t, err)
+	ret, ok := reopened.Get(cb.ID)
+	assert.True(t, ok)
+	assert.Equal(t, cb, ret)
+	assert.Equal(t, []Callback{cb}, reopened.List())
+}
+
+func TestCallbackMapGenerateID(t *testing.T) {
+	t.Parallel()
+	store := NewCallbackMap(time.Hour)
+	ids := make(map[string]struct{}, 100)
+	for i := 0; i < 100; i++ {
+		id := store.GenerateID()
+		assert.Len(t, id, callbackIDBytes*2)
+		assert.Len(t, ShortCallbackID(id), shortCallbackIDLength)
+		ids[id] = struct{}{}
+	}
+	assert.Len(t, ids, 100)
+}
+
+func TestCallbackMapSweep(t *testing.T) {
+	t.Parallel()
+	now := time.Now()
+	store := NewCallbackMap(time.Hour)
+	store.timeNow = func() time.Time { return now }
+	expired := Callback{ID: store.GenerateID(), CreatedAt: now.Add(-2 * time.Hour)}
+	pending := Callback{ID: store.GenerateID(), CreatedAt: now.Add(-30 * time.Minute)}
+	assert.NoError(t, store.Set(expired))
+	assert.NoError(t, store.Set(pending))
+
+	_, ok := store.Get(expired.ID)
+	assert.False(t, ok)
+	assert.Equal(t, []Callback{pending}, store.List())
+
+	ret, err := store.Sweep()
+	assert.NoError(t, err)
+	assert.Equal(t, []Callback{expired}, ret)
+	ret, err = store.Sweep()
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+}
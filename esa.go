@@ -2,37 +2,79 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
 	"net/url"
+	"sync"
 	"time"
 )
 
+const (
+	// maxListAccountPages is the upper limit of pages to follow, to not loop forever on unexpected responses.
+	maxListAccountPages = 100
+
+	defaultMaxRetries    = 3
+	defaultRetryBaseWait = time.Second
+	defaultMaxRetryWait  = 15 * time.Minute // esa resets the rate limit every 15 minutes
+)
+
 //
 type EsaClient struct {
-	endpoint string
-	teamName string
-	token    string
-	http     *http.Client
+	endpoint      string
+	teamName      string
+	token         string
+	http          *http.Client
+	maxRetries    int
+	retryBaseWait time.Duration
+	maxRetryWait  time.Duration
+	mu            sync.Mutex
+	rateLimit     *RateLimit
+}
+
+// EsaClientOption configures the EsaClient.
+type EsaClientOption func(*EsaClient)
+
+// EsaClientOptionTimeout sets the time limit for each http request, zero means no timeout.
+func EsaClientOptionTimeout(value time.Duration) EsaClientOption {
+	return func(in *EsaClient) {
+		in.http.Timeout = value
+	}
+}
+
+// EsaClientOptionRetry sets the retry count and the base wait of the exponential backoff for idempotent requests.
+func EsaClientOptionRetry(maxRetries int, baseWait time.Duration) EsaClientOption {
+	return func(in *EsaClient) {
+		in.maxRetries = maxRetries
+		in.retryBaseWait = baseWait
+	}
 }
 
 //
-func NewEsaClient(teamName, token string) (*EsaClient, error) {
+func NewEsaClient(teamName, token string, options ...EsaClientOption) (*EsaClient, error) {
 	if teamName == "" {
 		return nil, errors.New("teamName is required")
 	}
 	if token == "" {
 		return nil, errors.New("token is required")
 	}
-	return &EsaClient{
-		endpoint: "https://api.esa.io/v1/teams/" + teamName,
-		teamName: teamName,
-		token:    token,
-		http:     &http.Client{},
-	}, nil
+	c := &EsaClient{
+		endpoint:      "https://api.esa.io/v1/teams/" + teamName,
+		teamName:      teamName,
+		token:         token,
+		http:          &http.Client{},
+		maxRetries:    defaultMaxRetries,
+		retryBaseWait: defaultRetryBaseWait,
+		maxRetryWait:  defaultMaxRetryWait,
+	}
+	for _, opt := range options {
+		opt(c)
+	}
+	return c, nil
 }
 
 type QueryOption func(*url.URL) error
@@ -103,53 +145,131 @@ func (c *EsaClient) GetTeamName() string {
 	return c.teamName
 }
 
+// RateLimit returns the latest api quota reported by esa, it returns false before the first request.
+func (c *EsaClient) RateLimit() (RateLimit, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.rateLimit == nil {
+		return RateLimit{}, false
+	}
+	return *c.rateLimit, true
+}
+
+// do sends the request, it waits for the rate limit reset and retries with backoff on 429 and 5xx
+// only if the method is idempotent.
+func (c *EsaClient) do(ctx context.Context, method, url string, body []byte) (*http.Response, error) {
+	idempotent := method == http.MethodGet || method == http.MethodDelete
+	for attempt := 0; ; attempt++ {
+		if err := sleepContext(ctx, c.waitForQuota()); err != nil {
+			return nil, err
+		}
+		var reader io.Reader
+		if body != nil {
+			reader = bytes.NewReader(body)
+		}
+		req, err := http.NewRequestWithContext(ctx, method, url, reader)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Authorization", "Bearer "+c.token)
+		if body != nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		res, err := c.http.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		if rateLimit, ok := parseRateLimit(res.Header); ok {
+			c.mu.Lock()
+			c.rateLimit = &rateLimit
+			c.mu.Unlock()
+		}
+		// the previous attempt may have deleted the resource even if its response was an error
+		if method == http.MethodDelete && attempt > 0 && res.StatusCode == http.StatusNotFound {
+			res.Body.Close()
+			logger.Warningf("Treat %s %s as succeeded, the resource has been deleted by the previous attempt", method, req.URL.Path)
+			return &http.Response{
+				Status:     http.StatusText(http.StatusNoContent),
+				StatusCode: http.StatusNoContent,
+				Header:     res.Header,
+				Body:       ioutil.NopCloser(bytes.NewReader(nil)),
+				Request:    req,
+			}, nil
+		}
+		if !idempotent || attempt >= c.maxRetries || !isRetryableStatus(res.StatusCode) {
+			return res, nil
+		}
+		wait := c.retryWait(attempt, res)
+		res.Body.Close()
+		logger.Warningf("Retry %s %s in %s, status code is %d", method, req.URL.Path, wait, res.StatusCode)
+		if err := sleepContext(ctx, wait); err != nil {
+			return nil, err
+		}
+	}
+}
+
 //
 func (c *EsaClient) InviteAccount(email string) error {
+	return c.InviteAccountContext(context.Background(), email)
+}
+
+//
+func (c *EsaClient) InviteAccountContext(ctx context.Context, email string) error {
+	return c.InviteAccountsContext(ctx, []string{email})
+}
+
+// InviteRequest is the request body of the invitation api.
+type InviteRequest struct {
+	Member InviteMember `json:"member"`
+}
+
+type InviteMember struct {
+	Emails []string `json:"emails"`
+}
+
+// InviteAccounts sends the invitation emails at once.
+func (c *EsaClient) InviteAccounts(emails []string) error {
+	return c.InviteAccountsContext(context.Background(), emails)
+}
+
+//
+func (c *EsaClient) InviteAccountsContext(ctx context.Context, emails []string) error {
+	if len(emails) == 0 {
+		return errors.New("emails are required")
+	}
 	url, err := buildURL(c.endpoint + "/invitations")
 	if err != nil {
 		return err
 	}
-	body := bytes.NewBuffer([]byte("{\"member\":{\"emails\":[\"" + email + "\"]}}"))
-	req, err := http.NewRequest(http.MethodPost, url, body)
+	body, err := json.Marshal(InviteRequest{Member: InviteMember{Emails: emails}})
 	if err != nil {
 		return err
 	}
-	req.Header.Set("Authorization", "Bearer "+c.token)
-	req.Header.Set("Content-Type", "application/json")
-	res, err := c.http.Do(req)
+	res, err := c.do(ctx, http.MethodPost, url, body)
 	if err != nil {
 		return err
 	}
 	defer res.Body.Close()
-	if res.StatusCode < 200 || 300 <= res.StatusCode {
-		return fmt.Errorf("invalid status code: %d", res.StatusCode)
-	}
-	return nil
+	return checkResponse(res)
 }
 
 //
 func (c *EsaClient) DeleteAccount(screenName string) error {
+	return c.DeleteAccountContext(context.Background(), screenName)
+}
+
+//
+func (c *EsaClient) DeleteAccountContext(ctx context.Context, screenName string) error {
 	url, err := buildURL(c.endpoint + "/members/" + screenName)
 	if err != nil {
 		return err
 	}
-	req, err := http.NewRequest(http.MethodDelete, url, nil)
-	if err != nil {
-		return err
-	}
-	req.Header.Set("Authorization", "Bearer "+c.token)
-	res, err := c.http.Do(req)
+	res, err := c.do(ctx, http.MethodDelete, url, nil)
 	if err != nil {
 		return err
 	}
 	defer res.Body.Close()
-	if res.StatusCode == 404 {
-		return fmt.Errorf("status code is 404, the specified account (%s) has already been deleted", screenName)
-	}
-	if res.StatusCode < 200 || 300 <= res.StatusCode {
-		return fmt.Errorf("invalid status code: %d", res.StatusCode)
-	}
-	return nil
+	return checkResponse(res)
 }
 
 type ListAccountResponse struct {
@@ -182,22 +302,22 @@ func (m *Member) JoinedTime() (time.Time, error) {
 
 //
 func (c *EsaClient) ListAccount(options ...QueryOption) (*ListAccountResponse, error) {
+	return c.ListAccountContext(context.Background(), options...)
+}
+
+//
+func (c *EsaClient) ListAccountContext(ctx context.Context, options ...QueryOption) (*ListAccountResponse, error) {
 	url, err := buildURL(c.endpoint+"/members", options...)
 	if err != nil {
 		return nil, err
 	}
-	req, err := http.NewRequest(http.MethodGet, url, nil)
-	if err != nil {
-		return nil, err
-	}
-	req.Header.Set("Authorization", "Bearer "+c.token)
-	res, err := c.http.Do(req)
+	res, err := c.do(ctx, http.MethodGet, url, nil)
 	if err != nil {
 		return nil, err
 	}
 	defer res.Body.Close()
-	if res.StatusCode < 200 || 300 <= res.StatusCode {
-		return nil, fmt.Errorf("invalid status code: %d", res.StatusCode)
+	if err := checkResponse(res); err != nil {
+		return nil, err
 	}
 	data, err := ioutil.ReadAll(res.Body)
 	if err != nil {
@@ -209,3 +329,43 @@ func (c *EsaClient) ListAccount(options ...QueryOption) (*ListAccountResponse, e
 	}
 	return ret, nil
 }
+
+// EachAccount calls fn for each member by following the next page, until fn returns false.
+func (c *EsaClient) EachAccount(ctx context.Context, fn func(*Member) bool, options ...QueryOption) error {
+	page := 1
+	for i := 0; i < maxListAccountPages; i++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		opts := make([]QueryOption, 0, len(options)+1)
+		opts = append(opts, options...)
+		opts = append(opts, QueryOptionPage(page))
+		res, err := c.ListAccountContext(ctx, opts...)
+		if err != nil {
+			return err
+		}
+		for _, member := range res.Members {
+			if !fn(member) {
+				return nil
+			}
+		}
+		if res.NextPage == 0 { // next_page is null on the last page
+			return nil
+		}
+		page = res.NextPage
+	}
+	return fmt.Errorf("too many pages, exceeded the limit of %d pages", maxListAccountPages)
+}
+
+// ListAllAccounts returns the all members of the team.
+func (c *EsaClient) ListAllAccounts(ctx context.Context, options ...QueryOption) ([]*Member, error) {
+	var ret []*Member
+	err := c.EachAccount(ctx, func(member *Member) bool {
+		ret = append(ret, member)
+		return true
+	}, options...)
+	if err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+)
+
+// EsaAPIError is the error response of esa, see https://docs.esa.io/posts/102#%E3%82%A8%E3%83%A9%E3%83%BC%E3%83%AC%E3%82%B9%E3%83%9D%E3%83%B3%E3%82%B9
+type EsaAPIError struct {
+	StatusCode int    `json:"-"`
+	Method     string `json:"-"`
+	Path       string `json:"-"`
+	Code       string `json:"error"`
+	Message    string `json:"message"`
+}
+
+//
+func (e *EsaAPIError) Error() string {
+	ret := fmt.Sprintf("esa api error: %s %s: status code %d", e.Method, e.Path, e.StatusCode)
+	if e.Code != "" {
+		ret += ", " + e.Code
+	}
+	if e.Message != "" {
+		ret += ": " + e.Message
+	}
+	return ret
+}
+
+// checkResponse returns EsaAPIError if the response status code is not 2xx.
+func checkResponse(res *http.Response) error {
+	if 200 <= res.StatusCode && res.StatusCode < 300 {
+		return nil
+	}
+	ret := &EsaAPIError{
+		StatusCode: res.StatusCode,
+		Method:     res.Request.Method,
+		Path:       res.Request.URL.Path,
+	}
+	data, err := ioutil.ReadAll(res.Body)
+	if err == nil {
+		json.Unmarshal(data, ret) // ignore error, the body is not always json
+	}
+	return ret
+}
+
+// IsNotFound returns true if the error is caused by 404 response of esa.
+func IsNotFound(err error) bool {
+	var apiErr *EsaAPIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound
+}
+
+// IsRateLimited returns true if the error is caused by 429 response of esa.
+func IsRateLimited(err error) bool {
+	var apiErr *EsaAPIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusTooManyRequests
+}
+
+// IsUnauthorized returns true if the error is caused by 401 or 403 response of esa.
+func IsUnauthorized(err error) bool {
+	var apiErr *EsaAPIError
+	return errors.As(err, &apiErr) && (apiErr.StatusCode == http.StatusUnauthorized || apiErr.StatusCode == http.StatusForbidden)
+}
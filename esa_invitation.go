@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+)
+
+type ListInvitationResponse struct {
+	Invitations []*Invitation `json:"invitations"`
+	PrevPage    int           `json:"prev_page"`
+	NextPage    int           `json:"next_page"`
+	TotalCount  int           `json:"total_count"`
+	Page        int           `json:"page"`
+	PerPage     int           `json:"per_page"`
+	MaxPerPage  int           `json:"max_per_page"`
+}
+
+type Invitation struct {
+	Email     string `json:"email"`
+	Code      string `json:"code"`
+	ExpiresAt string `json:"expires_at"`
+	URL       string `json:"url"`
+}
+
+func (i *Invitation) ExpiresTime() (time.Time, error) {
+	return time.Parse(time.RFC3339, i.ExpiresAt)
+}
+
+//
+func (c *EsaClient) ListInvitation(options ...QueryOption) (*ListInvitationResponse, error) {
+	return c.ListInvitationContext(context.Background(), options...)
+}
+
+//
+func (c *EsaClient) ListInvitationContext(ctx context.Context, options ...QueryOption) (*ListInvitationResponse, error) {
+	url, err := buildURL(c.endpoint+"/invitations", options...)
+	if err != nil {
+		return nil, err
+	}
+	res, err := c.do(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if err := checkResponse(res); err != nil {
+		return nil, err
+	}
+	data, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+	var ret *ListInvitationResponse
+	if err := json.Unmarshal(data, &ret); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// ListAllInvitations returns the all pending invitations by following the next page.
+func (c *EsaClient) ListAllInvitations(ctx context.Context) ([]*Invitation, error) {
+	var ret []*Invitation
+	page := 1
+	for i := 0; i < maxListAccountPages; i++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		res, err := c.ListInvitationContext(ctx, QueryOptionPage(page), QueryOptionPerPage(100))
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, res.Invitations...)
+		if res.NextPage == 0 { // next_page is null on the last page
+			return ret, nil
+		}
+		page = res.NextPage
+	}
+	return nil, fmt.Errorf("too many pages, exceeded the limit of %d pages", maxListAccountPages)
+}
+
+// FindInvitation returns the pending invitation for the email, it returns nil if not found.
+func (c *EsaClient) FindInvitation(ctx context.Context, email string) (*Invitation, error) {
+	invitations, err := c.ListAllInvitations(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range invitations {
+		if v.Email == email {
+			return v, nil
+		}
+	}
+	return nil, nil
+}
+
+// DeleteInvitation revokes the invitation specified by code.
+func (c *EsaClient) DeleteInvitation(code string) error {
+	return c.DeleteInvitationContext(context.Background(), code)
+}
+
+//
+func (c *EsaClient) DeleteInvitationContext(ctx context.Context, code string) error {
+	url, err := buildURL(c.endpoint + "/invitations/" + code)
+	if err != nil {
+		return err
+	}
+	res, err := c.do(ctx, http.MethodDelete, url, nil)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	return checkResponse(res)
+}
+
+// ReinviteAccountContext revokes the pending invitation for the email if exists, and sends a new invitation.
+func (c *EsaClient) ReinviteAccountContext(ctx context.Context, email string) error {
+	invitation, err := c.FindInvitation(ctx, email)
+	if err != nil {
+		return err
+	}
+	if invitation != nil {
+		if err := c.DeleteInvitationContext(ctx, invitation.Code); err != nil && !IsNotFound(err) {
+			return err
+		}
+	}
+	return c.InviteAccountContext(ctx, email)
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestInvitationServer returns the esa server which has the pending invitations in 2 pages.
+func newTestInvitationServer(t *testing.T) (*EsaClient, *httptest.Server) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/invitations":
+			assert.Equal(t, "100", r.URL.Query().Get("per_page"))
+			switch r.URL.Query().Get("page") {
+			case "1":
+				fmt.Fprint(w, `{"invitations":[{"email":"foo@example.com","code":"0001"},{"email":"bar@example.com","code":"0002"}],"next_page":2}`)
+			case "2":
+				fmt.Fprint(w, `{"invitations":[{"email":"baz@example.com","code":"0003"}],"next_page":null}`)
+			default:
+				w.WriteHeader(http.StatusNotFound)
+			}
+		case r.Method == http.MethodDelete && r.URL.Path == "/invitations/0001":
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"error":"not_found","message":"Not found"}`)
+		}
+	}))
+	client, err := NewEsaClient("test", "token")
+	assert.NoError(t, err)
+	client.endpoint = server.URL
+	return client, server
+}
+
+func TestListAllInvitations(t *testing.T) {
+	t.Parallel()
+	client, server := newTestInvitationServer(t)
+	defer server.Close()
+
+	invitations, err := client.ListAllInvitations(context.Background())
+	assert.NoError(t, err)
+	codes := make([]string, 0, len(invitations))
+	for _, invitation := range invitations {
+		codes = append(codes, invitation.Code)
+	}
+	assert.Equal(t, []string{"0001", "0002", "0003"}, codes)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = client.ListAllInvitations(ctx)
+	assert.Equal(t, context.Canceled, err)
+}
+
+func TestFindInvitation(t *testing.T) {
+	t.Parallel()
+	client, server := newTestInvitationServer(t)
+	defer server.Close()
+
+	tests := []struct {
+		email      string
+		expectCode string
+	}{
+		{email: "foo@example.com", expectCode: "0001"},
+		{email: "baz@example.com", expectCode: "0003"}, // on the last page
+		{email: "qux@example.com", expectCode: ""},
+	}
+	for _, tt := range tests {
+		invitation, err := client.FindInvitation(context.Background(), tt.email)
+		assert.NoError(t, err, tt.email)
+		if tt.expectCode == "" {
+			assert.Nil(t, invitation, tt.email)
+			continue
+		}
+		if assert.NotNil(t, invitation, tt.email) {
+			assert.Equal(t, tt.expectCode, invitation.Code, tt.email)
+		}
+	}
+}
+
+func TestDeleteInvitation(t *testing.T) {
+	t.Parallel()
+	client, server := newTestInvitationServer(t)
+	defer server.Close()
+
+	assert.NoError(t, client.DeleteInvitationContext(context.Background(), "0001"))
+
+	err := client.DeleteInvitationContext(context.Background(), "9999")
+	assert.True(t, IsNotFound(err))
+	assert.Equal(t, &EsaAPIError{
+		StatusCode: http.StatusNotFound,
+		Method:     http.MethodDelete,
+		Path:       "/invitations/9999",
+		Code:       "not_found",
+		Message:    "Not found",
+	}, err)
+}
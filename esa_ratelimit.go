@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RateLimit is the api quota of esa, see https://docs.esa.io/posts/102#%E5%88%A9%E7%94%A8%E5%88%B6%E9%99%90
+type RateLimit struct {
+	Limit     int
+	Remaining int
+	Reset     time.Time
+}
+
+// parseRateLimit parses the X-RateLimit-* headers, it returns false if the headers are missing.
+func parseRateLimit(header http.Header) (RateLimit, bool) {
+	limit, err := strconv.Atoi(header.Get("X-RateLimit-Limit"))
+	if err != nil {
+		return RateLimit{}, false
+	}
+	remaining, err := strconv.Atoi(header.Get("X-RateLimit-Remaining"))
+	if err != nil {
+		return RateLimit{}, false
+	}
+	reset, err := strconv.ParseInt(header.Get("X-RateLimit-Reset"), 10, 64)
+	if err != nil {
+		return RateLimit{}, false
+	}
+	return RateLimit{
+		Limit:     limit,
+		Remaining: remaining,
+		Reset:     time.Unix(reset, 0),
+	}, true
+}
+
+//
+func isRetryableStatus(statusCode int) bool {
+	return statusCode == http.StatusTooManyRequests || 500 <= statusCode
+}
+
+// waitForQuota returns the duration until the rate limit reset if the quota has run out.
+func (c *EsaClient) waitForQuota() time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.rateLimit == nil || c.rateLimit.Remaining > 0 {
+		return 0
+	}
+	return c.capRetryWait(time.Until(c.rateLimit.Reset))
+}
+
+// retryWait returns the duration until the rate limit reset on 429, or the exponential backoff on others.
+func (c *EsaClient) retryWait(attempt int, res *http.Response) time.Duration {
+	if res.StatusCode == http.StatusTooManyRequests {
+		if rateLimit, ok := parseRateLimit(res.Header); ok && time.Now().Before(rateLimit.Reset) {
+			return c.capRetryWait(time.Until(rateLimit.Reset))
+		}
+	}
+	return c.capRetryWait(c.retryBaseWait << uint(attempt))
+}
+
+//
+func (c *EsaClient) capRetryWait(wait time.Duration) time.Duration {
+	if wait < 0 {
+		return 0
+	}
+	if wait > c.maxRetryWait {
+		return c.maxRetryWait
+	}
+	return wait
+}
+
+// sleepContext pauses until the duration elapses or the context is done.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -1,7 +1,14 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -46,3 +53,142 @@ func TestBuildURL(t *testing.T) {
 		})
 	}
 }
+
+func TestListAllAccounts(t *testing.T) {
+	t.Parallel()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Query().Get("page") {
+		case "1":
+			fmt.Fprint(w, `{"members":[{"screen_name":"foo"},{"screen_name":"bar"}],"next_page":2}`)
+		case "2":
+			fmt.Fprint(w, `{"members":[{"screen_name":"baz"}],"next_page":null}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	client, err := NewEsaClient("test", "token")
+	assert.NoError(t, err)
+	client.endpoint = server.URL
+
+	members, err := client.ListAllAccounts(context.Background(), QueryOptionPerPage(2))
+	assert.NoError(t, err)
+	screenNames := make([]string, 0, len(members))
+	for _, member := range members {
+		screenNames = append(screenNames, member.ScreenName)
+	}
+	assert.Equal(t, []string{"foo", "bar", "baz"}, screenNames)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = client.ListAllAccounts(ctx)
+	assert.Equal(t, context.Canceled, err)
+}
+
+func TestEsaClientRetry(t *testing.T) {
+	t.Parallel()
+	reset := time.Now().Add(time.Hour).Unix()
+	var count int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		count++
+		w.Header().Set("X-RateLimit-Limit", "75")
+		w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(75-count))
+		w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(reset, 10))
+		switch {
+		case count == 1:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case r.Method == http.MethodPost:
+			w.WriteHeader(http.StatusBadGateway)
+		default:
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer server.Close()
+	client, err := NewEsaClient("test", "token", EsaClientOptionRetry(2, time.Millisecond))
+	assert.NoError(t, err)
+	client.endpoint = server.URL
+	_, ok := client.RateLimit()
+	assert.False(t, ok)
+
+	// idempotent request is retried
+	assert.NoError(t, client.DeleteAccount("foo"))
+	assert.Equal(t, 2, count)
+	rateLimit, ok := client.RateLimit()
+	assert.True(t, ok)
+	assert.Equal(t, RateLimit{Limit: 75, Remaining: 73, Reset: time.Unix(reset, 0)}, rateLimit)
+
+	// non-idempotent request is not retried
+	assert.Error(t, client.InviteAccount("foo@example.com"))
+	assert.Equal(t, 3, count)
+}
+
+func TestEsaClientRetryDeleteNotFound(t *testing.T) {
+	t.Parallel()
+	var count int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		count++
+		if count == 1 {
+			// the account is deleted but the response is lost
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, `{"error":"not_found","message":"Not found"}`)
+	}))
+	defer server.Close()
+	client, err := NewEsaClient("test", "token", EsaClientOptionRetry(2, time.Millisecond))
+	assert.NoError(t, err)
+	client.endpoint = server.URL
+
+	// 404 of the retried request means the previous attempt has succeeded
+	assert.NoError(t, client.DeleteAccount("foo"))
+	assert.Equal(t, 2, count)
+
+	// 404 of the first attempt is still an error
+	count = 1
+	assert.True(t, IsNotFound(client.DeleteAccount("foo")))
+	assert.Equal(t, 2, count)
+}
+
+func TestEsaAPIError(t *testing.T) {
+	t.Parallel()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, `{"error":"not_found","message":"Not found"}`)
+	}))
+	defer server.Close()
+	client, err := NewEsaClient("test", "token")
+	assert.NoError(t, err)
+	client.endpoint = server.URL
+
+	err = client.DeleteAccount("foo")
+	assert.True(t, IsNotFound(err))
+	assert.False(t, IsRateLimited(err))
+	assert.Equal(t, &EsaAPIError{
+		StatusCode: http.StatusNotFound,
+		Method:     http.MethodDelete,
+		Path:       "/members/foo",
+		Code:       "not_found",
+		Message:    "Not found",
+	}, err)
+}
+
+func TestInviteAccounts(t *testing.T) {
+	t.Parallel()
+	var body InviteRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/invitations", r.URL.Path)
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+	client, err := NewEsaClient("test", "token")
+	assert.NoError(t, err)
+	client.endpoint = server.URL
+
+	emails := []string{`foo"]}}@example.com`, "bar@example.com"}
+	assert.NoError(t, client.InviteAccounts(emails))
+	assert.Equal(t, emails, body.Member.Emails)
+	assert.Error(t, client.InviteAccounts(nil))
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/nlopes/slack"
+	"github.com/nlopes/slack/slackevents"
+)
+
+const (
+	// eventDedupWindow is the period to remember the handled messages,
+	// since a mention is delivered as both app_mention and message events.
+	eventDedupWindow = 10 * time.Minute
+)
+
+// EventHandler handles the Events API requests, and dispatches the messages to the MessageListener.
+type EventHandler struct {
+	listener *MessageListener
+	mu       sync.Mutex
+	handled  map[string]time.Time
+}
+
+//
+func NewEventHandler(listener *MessageListener) *EventHandler {
+	return &EventHandler{
+		listener: listener,
+		handled:  map[string]time.Time{},
+	}
+}
+
+//
+func (h *EventHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		logger.Errorf("Invalid method: %s", r.Method)
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	buf, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxRequestBodySize))
+	if err != nil {
+		logger.Errorf("Failed to read request body: %s", err.Error())
+		w.WriteHeader(http.StatusRequestEntityTooLarge)
+		return
+	}
+	// the request has already been verified by the signing secret
+	event, err := slackevents.ParseEvent(json.RawMessage(buf), slackevents.OptionNoVerifyToken())
+	if err != nil {
+		logger.Errorf("Failed to parse event: %s", err.Error())
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	switch event.Type {
+	case slackevents.URLVerification:
+		verification, ok := event.Data.(*slackevents.EventsAPIURLVerificationEvent)
+		if !ok {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(verification.Challenge)) // ignore write error
+	case slackevents.CallbackEvent:
+		// slack retries the event unless it receives the response within 3 seconds, so it handles the event asynchronously
+		w.WriteHeader(http.StatusOK)
+		if ev := h.toMessageEvent(event.InnerEvent.Data); ev != nil && h.markHandled(ev) {
+			go h.listener.dispatch(ev)
+		}
+	default:
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// toMessageEvent converts the inner event to the RTM message event, it returns nil for unsupported events.
+func (h *EventHandler) toMessageEvent(data interface{}) *slack.MessageEvent {
+	switch ev := data.(type) {
+	case *slackevents.AppMentionEvent:
+		return &slack.MessageEvent{Msg: slack.Msg{
+			Type:      "message",
+			Channel:   ev.Channel,
+			User:      ev.User,
+			Text:      ev.Text,
+			Timestamp: ev.TimeStamp,
+		}}
+	case *slackevents.MessageEvent:
+		if ev.SubType != "" || ev.BotID != "" { // ignore edited, deleted and bot messages
+			return nil
+		}
+		return &slack.MessageEvent{Msg: slack.Msg{
+			Type:      "message",
+			Channel:   ev.Channel,
+			User:      ev.User,
+			Text:      ev.Text,
+			Timestamp: ev.TimeStamp,
+		}}
+	default:
+		return nil
+	}
+}
+
+// markHandled remembers the message, it returns false if the message has already been handled.
+func (h *EventHandler) markHandled(ev *slack.MessageEvent) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	now := time.Now()
+	for key, t := range h.handled {
+		if now.Sub(t) > eventDedupWindow {
+			delete(h.handled, key)
+		}
+	}
+	key := ev.Channel + ":" + ev.Timestamp
+	if _, ok := h.handled[key]; ok {
+		return false
+	}
+	h.handled[key] = now
+	return true
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nlopes/slack"
+	"github.com/nlopes/slack/slackevents"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEventHandlerServeHTTP(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name         string
+		method       string
+		body         string
+		expectStatus int
+		expectBody   string
+	}{
+		{
+			name:         "url verification",
+			method:       http.MethodPost,
+			body:         `{"type":"url_verification","token":"token","challenge":"3eZbrw1aBm2rZgRNFdxV2595E9CY3gmdALWMmHkvFXO7tYXAYM8P"}`,
+			expectStatus: http.StatusOK,
+			expectBody:   "3eZbrw1aBm2rZgRNFdxV2595E9CY3gmdALWMmHkvFXO7tYXAYM8P",
+		},
+		{
+			name:         "invalid method",
+			method:       http.MethodGet,
+			expectStatus: http.StatusMethodNotAllowed,
+		},
+		{
+			name:         "invalid body",
+			method:       http.MethodPost,
+			body:         `{`,
+			expectStatus: http.StatusBadRequest,
+		},
+		{
+			name:         "bot message is ignored",
+			method:       http.MethodPost,
+			body:         `{"type":"event_callback","event":{"type":"message","channel":"C0001","bot_id":"B0001","text":"hello","ts":"1585699200.000100"}}`,
+			expectStatus: http.StatusOK,
+		},
+		{
+			name:         "edited message is ignored",
+			method:       http.MethodPost,
+			body:         `{"type":"event_callback","event":{"type":"message","subtype":"message_changed","channel":"C0001","ts":"1585699200.000100"}}`,
+			expectStatus: http.StatusOK,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			// the listener is not set, since the ignored events must not be dispatched
+			handler := NewEventHandler(nil)
+			req := httptest.NewRequest(tt.method, "/slack/events", strings.NewReader(tt.body))
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			assert.Equal(t, tt.expectStatus, rec.Code)
+			assert.Equal(t, tt.expectBody, rec.Body.String())
+			assert.Empty(t, handler.handled)
+		})
+	}
+}
+
+func TestEventHandlerToMessageEvent(t *testing.T) {
+	t.Parallel()
+	message := &slack.MessageEvent{Msg: slack.Msg{
+		Type:      "message",
+		Channel:   "C0001",
+		User:      "U0001",
+		Text:      "<@U0002> help",
+		Timestamp: "1585699200.000100",
+	}}
+	tests := []struct {
+		name   string
+		data   interface{}
+		expect *slack.MessageEvent
+	}{
+		{
+			name:   "app mention",
+			data:   &slackevents.AppMentionEvent{Channel: "C0001", User: "U0001", Text: "<@U0002> help", TimeStamp: "1585699200.000100"},
+			expect: message,
+		},
+		{
+			name:   "message",
+			data:   &slackevents.MessageEvent{Channel: "C0001", User: "U0001", Text: "<@U0002> help", TimeStamp: "1585699200.000100"},
+			expect: message,
+		},
+		{
+			name: "bot message",
+			data: &slackevents.MessageEvent{Channel: "C0001", BotID: "B0001", Text: "hello", TimeStamp: "1585699200.000100"},
+		},
+		{
+			name: "edited message",
+			data: &slackevents.MessageEvent{Channel: "C0001", User: "U0001", SubType: "message_changed", TimeStamp: "1585699200.000100"},
+		},
+		{
+			name: "unsupported event",
+			data: &slackevents.MemberJoinedChannelEvent{User: "U0001", Channel: "C0001"},
+		},
+	}
+	handler := NewEventHandler(nil)
+	for _, tt := range tests {
+		assert.Equal(t, tt.expect, handler.toMessageEvent(tt.data), tt.name)
+	}
+}
+
+func TestEventHandlerMarkHandled(t *testing.T) {
+	t.Parallel()
+	handler := NewEventHandler(nil)
+	mention := &slack.MessageEvent{Msg: slack.Msg{Channel: "C0001", Timestamp: "1585699200.000100"}}
+	message := &slack.MessageEvent{Msg: slack.Msg{Channel: "C0001", Timestamp: "1585699200.000100"}}
+	other := &slack.MessageEvent{Msg: slack.Msg{Channel: "C0002", Timestamp: "1585699200.000100"}}
+
+	// the mention is delivered as both app_mention and message events
+	assert.True(t, handler.markHandled(mention))
+	assert.False(t, handler.markHandled(message))
+	assert.True(t, handler.markHandled(other))
+
+	// the message is forgotten after the dedup window
+	handler.handled["C0001:1585699200.000100"] = handler.handled["C0001:1585699200.000100"].Add(-eventDedupWindow - time.Second)
+	assert.True(t, handler.markHandled(message))
+}
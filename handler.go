@@ -1,26 +1,32 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
-	"io/ioutil"
 	"net/http"
-	"net/url"
-
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/nlopes/slack"
 )
 
 // InteractionHandler handles interactive message response.
 type InteractionHandler struct {
-	esaClient         *EsaClient
-	slackClient       *slack.Client
-	repository        *Repository
-	channelID         string
-	verificationToken string
-	adminIDs          []string
-	adminGroupID      string
+	ctx          context.Context
+	wg           *sync.WaitGroup
+	mu           *sync.Mutex // guards the updates of the pending requests, not to revive a finished request by a concurrent approval
+	esaClient    *EsaClient
+	slackClient  *slack.Client
+	repository   *Repository
+	viewClient   *ViewClient
+	listener     *MessageListener
+	channelID    string
+	adminIDs     []string
+	adminGroupID string
+	quorum       map[string]int
+	fourEyes     []string
 }
 
 //
@@ -30,27 +36,39 @@ func (h InteractionHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusMethodNotAllowed)
 		return
 	}
-	buf, err := ioutil.ReadAll(r.Body)
-	if err != nil {
-		logger.Errorf("Failed to read request body: %s", err.Error())
-		w.WriteHeader(http.StatusInternalServerError)
+	r.Body = http.MaxBytesReader(w, r.Body, maxRequestBodySize)
+	if err := r.ParseForm(); err != nil {
+		logger.Errorf("Failed to parse request body: %s", err.Error())
+		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
-	body, err := url.QueryUnescape(string(buf)[8:])
-	if err != nil {
-		logger.Errorf("Failed to un-escape request body: %s", err.Error())
-		w.WriteHeader(http.StatusInternalServerError)
+	body := r.PostForm.Get("payload")
+	if body == "" {
+		logger.Errorf("Empty payload")
+		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
 	var message slack.InteractionCallback
 	if err := json.Unmarshal([]byte(body), &message); err != nil {
 		logger.Errorf("Failed to decode json message from slack: %s", body)
-		w.WriteHeader(http.StatusInternalServerError)
+		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
-	if message.Token != h.verificationToken {
-		logger.Errorf("Invalid token: %s", message.Token)
-		w.WriteHeader(http.StatusUnauthorized)
+	switch message.Type {
+	case slack.InteractionTypeBlockActions:
+	case interactionTypeShortcut:
+		if err := h.handleShortcut(w, message); err != nil {
+			logger.Errorf("Failed to handle shortcut: %s", err.Error())
+		}
+		return
+	case interactionTypeViewSubmission:
+		if err := h.handleViewSubmission(w, []byte(body)); err != nil {
+			logger.Errorf("Failed to handle view submission: %s", err.Error())
+		}
+		return
+	default:
+		logger.Errorf("Unexpected interaction type: %s", message.Type)
+		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
 	if message.Channel.ID != h.channelID {
@@ -66,353 +84,594 @@ func (h InteractionHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 //
 func (h InteractionHandler) handle(w http.ResponseWriter, message slack.InteractionCallback) error {
-	action := message.ActionCallback.AttachmentActions[0]
-	switch action.Name {
+	if len(message.ActionCallback.BlockActions) == 0 {
+		w.WriteHeader(http.StatusBadRequest)
+		return fmt.Errorf("no action was submitted")
+	}
+	action := message.ActionCallback.BlockActions[0]
+	switch action.ActionID {
 	case actionInviteConfirm:
-		return h.handleConfirm(w, message, actionInviteApprove)
+		return h.handleConfirm(w, message, action, actionInviteApprove)
 	case actionInviteSelectOrganization:
-		return h.handleInviteSelectOrganization(w, message)
+		return h.handleInviteSelectOrganization(w, message, action)
 	case actionInviteApprove:
-		return h.handleInviteApprove(w, message)
+		return h.handleInviteApprove(w, message, action)
 	case actionDeleteConfirm:
-		return h.handleConfirm(w, message, actionDeleteApprove)
+		return h.handleConfirm(w, message, action, actionDeleteApprove)
 	case actionDeleteApprove:
-		return h.handleDeleteApprove(w, message)
+		return h.handleDeleteApprove(w, message, action)
 	case actionCleanupConfirm:
-		return h.handleConfirm(w, message, actionCleanupApprove)
+		return h.handleConfirm(w, message, action, actionCleanupApprove)
 	case actionCleanupApprove:
-		return h.handleCleanupApprove(w, message)
+		return h.handleCleanupApprove(w, message, action)
+	case actionRevokeConfirm:
+		return h.handleConfirm(w, message, action, actionRevokeApprove)
+	case actionRevokeApprove:
+		return h.handleRevokeApprove(w, message, action)
 	case actionCancel:
-		return h.handleCancel(w, message)
+		return h.handleCancel(w, message, action)
 	case actionReject:
-		return h.handleReject(w, message)
+		return h.handleReject(w, message, action)
 	default:
-		w.WriteHeader(http.StatusInternalServerError)
-		return fmt.Errorf("invalid action was submitted: %s", action.Name)
+		w.WriteHeader(http.StatusBadRequest)
+		return fmt.Errorf("invalid action was submitted: %s", action.ActionID)
 	}
 }
 
 //
-func (h InteractionHandler) handleCancel(w http.ResponseWriter, message slack.InteractionCallback) error {
-	cb, ok := h.repository.Callbacks().Get(message.CallbackID)
+func (h InteractionHandler) handleCancel(w http.ResponseWriter, message slack.InteractionCallback, action *slack.BlockAction) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	cb, ok := h.repository.Callbacks().Get(action.BlockID)
 	if !ok {
-		text := ":x: Request has expired: " + message.CallbackID
-		return h.responseError(w, message.OriginalMessage, text)
+		return h.responseExpired(w, message, action.BlockID)
 	}
-	original := message.OriginalMessage
 	if cb.OwnerUser.ID != message.User.ID {
 		text := fmt.Sprintf(":warning: %s does not have cancel permission", WrapUserNameInLink(message.User.Name))
-		return h.responseHint(w, original, text)
+		return h.responseHint(w, cb, text)
 	}
+	h.deleteCallback(cb)
+	h.repository.Audit(auditEventCanceled, cb, interactionUser(message), "")
 	text := fmt.Sprintf(":x: %s canceled the request", WrapUserNameInLink(message.User.Name))
-	return h.responseWarning(w, original, text)
+	return h.responseWarning(w, cb, text)
 }
 
 //
-func (h InteractionHandler) handleReject(w http.ResponseWriter, message slack.InteractionCallback) error {
-	cb, ok := h.repository.Callbacks().Get(message.CallbackID)
+func (h InteractionHandler) handleReject(w http.ResponseWriter, message slack.InteractionCallback, action *slack.BlockAction) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	cb, ok := h.repository.Callbacks().Get(action.BlockID)
 	if !ok {
-		text := ":x: Request has expired: " + message.CallbackID
-		return h.responseError(w, message.OriginalMessage, text)
+		return h.responseExpired(w, message, action.BlockID)
 	}
-	original := message.OriginalMessage
 	if !h.repository.IsAdminUserID(message.User.ID) && cb.OwnerUser.ID != message.User.ID {
 		text := fmt.Sprintf(":warning: %s does not have reject permission", WrapUserNameInLink(message.User.Name))
-		return h.responseHint(w, original, text)
+		return h.responseHint(w, cb, text)
 	}
+	h.deleteCallback(cb)
+	h.repository.Audit(auditEventRejected, cb, interactionUser(message), "")
 	text := fmt.Sprintf(":x: %s rejected the request", WrapUserNameInLink(message.User.Name))
-	return h.responseWarning(w, original, text)
+	return h.responseWarning(w, cb, text)
 }
 
 //
-func (h InteractionHandler) handleConfirm(w http.ResponseWriter, message slack.InteractionCallback, nextAction string) error {
-	cb, ok := h.repository.Callbacks().Get(message.CallbackID)
+func (h InteractionHandler) handleConfirm(w http.ResponseWriter, message slack.InteractionCallback, action *slack.BlockAction, nextAction string) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	cb, ok := h.repository.Callbacks().Get(action.BlockID)
 	if !ok {
-		text := ":x: Request has expired: " + message.CallbackID
-		return h.responseError(w, message.OriginalMessage, text)
+		return h.responseExpired(w, message, action.BlockID)
 	}
-	original := message.OriginalMessage
 	if cb.OwnerUser.ID != message.User.ID {
 		text := fmt.Sprintf(":warning: %s does not have confirm permission", WrapUserNameInLink(message.User.Name))
-		return h.responseHint(w, original, text)
+		return h.responseHint(w, cb, text)
 	}
-	h.setSuccessToLastAttachment(original.Attachments, "")
-	var admins string
-	if h.adminGroupID != "" {
-		admins = WrapUserGroupIDInLink(h.adminGroupID)
-	} else {
-		for _, v := range h.adminIDs {
-			if admins == "" {
-				admins = WrapUserGroupIDInLink(v)
-			} else {
-				admins += " " + WrapUserGroupIDInLink(v)
-			}
-		}
+	setLastStage(cb.Stages, StageStatusSuccess, "")
+	cb.Stages = append(cb.Stages, h.reviewStage(cb, nextAction))
+	if err := h.repository.Callbacks().Set(cb); err != nil {
+		logger.Errorf("Failed to save request %s: %s", cb.ID, err.Error())
+		return h.responseError(w, cb, ":x: Failed to save request: "+err.Error())
 	}
-	original.Attachments = append(original.Attachments, slack.Attachment{
-		Title:      DateTimePrefix() + "Review",
-		Text:       ":pray: 管理者 " + admins + " の承認が必要です",
-		Color:      ColorCodeBlue,
-		CallbackID: cb.ID,
-		Actions: []slack.AttachmentAction{
+	h.repository.Audit(auditEventConfirmed, cb, interactionUser(message), "")
+	return h.response(w, cb)
+}
+
+// reviewStage returns the stage to wait for the approval of admins.
+func (h InteractionHandler) reviewStage(cb Callback, nextAction string) Stage {
+	return Stage{
+		Title:  DateTimePrefix() + "Review",
+		Text:   h.reviewText(cb),
+		Status: StageStatusPending,
+		Actions: []StageAction{
 			{
 				Name:  nextAction,
 				Text:  "Approve",
-				Type:  "button",
 				Style: "primary",
 			},
 			{
 				Name:  actionReject,
 				Text:  "Reject",
-				Type:  "button",
 				Style: "danger",
 			},
 		},
-	})
-	return h.response(w, &original)
+	}
+}
+
+// reviewText returns the text of the review stage, which shows the admins who have approved so far.
+func (h InteractionHandler) reviewText(cb Callback) string {
+	var admins string
+	if h.adminGroupID != "" {
+		admins = WrapUserGroupIDInLink(h.adminGroupID)
+	} else {
+		for _, v := range h.adminIDs {
+			if admins == "" {
+				admins = WrapUserGroupIDInLink(v)
+			} else {
+				admins += " " + WrapUserGroupIDInLink(v)
+			}
+		}
+	}
+	quorum := h.requiredApprovals(cb.Action)
+	text := ":pray: 管理者 " + admins + " の承認が必要です"
+	if quorum > 1 {
+		text = fmt.Sprintf(":pray: 管理者 %s のうち %d 名の承認が必要です", admins, quorum)
+	}
+	if h.requiresFourEyes(cb.Action) {
+		text += " (申請者自身は承認できません)"
+	}
+	if len(cb.Approvers) > 0 {
+		text += fmt.Sprintf("\n:white_check_mark: Approved (%d/%d): %s", len(cb.Approvers), quorum, approverNames(cb.Approvers))
+	}
+	return text
+}
+
+// requiredApprovals returns the number of the distinct admins required to approve the request action.
+func (h InteractionHandler) requiredApprovals(action string) int {
+	if v, ok := h.quorum[action]; ok && v > 1 {
+		return v
+	}
+	return 1
+}
+
+// requiresFourEyes returns true if the request action must be approved by an admin other than the requester.
+func (h InteractionHandler) requiresFourEyes(action string) bool {
+	for _, v := range h.fourEyes {
+		if v == action {
+			return true
+		}
+	}
+	return false
+}
+
+// approve records the approval of the user, and returns true if the request has reached the quorum to execute.
+// Otherwise it responses the progress of the approvals, and returns false.
+func (h InteractionHandler) approve(w http.ResponseWriter, message slack.InteractionCallback, cb *Callback) (bool, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	// get the latest approvals, since another admin may approve the request at the same time
+	current, ok := h.repository.Callbacks().Get(cb.ID)
+	if !ok {
+		return false, h.responseExpired(w, message, cb.ID)
+	}
+	*cb = current
+	if h.requiresFourEyes(cb.Action) && cb.OwnerUser.ID == message.User.ID {
+		text := fmt.Sprintf(":warning: %s cannot approve own request, it must be approved by another admin", WrapUserNameInLink(message.User.Name))
+		return false, h.responseHint(w, *cb, text)
+	}
+	for _, v := range cb.Approvers {
+		if v.ID == message.User.ID {
+			text := fmt.Sprintf(":warning: %s has already approved the request", WrapUserNameInLink(message.User.Name))
+			return false, h.responseHint(w, *cb, text)
+		}
+	}
+	cb.Approvers = append(cb.Approvers, interactionUser(message))
+	if len(cb.Approvers) >= h.requiredApprovals(cb.Action) {
+		h.deleteCallback(*cb)
+		h.repository.Audit(auditEventApproved, *cb, interactionUser(message), "")
+		return true, nil
+	}
+	if last := len(cb.Stages) - 1; last >= 0 {
+		cb.Stages[last].Text = h.reviewText(*cb)
+	}
+	if err := h.repository.Callbacks().Set(*cb); err != nil {
+		logger.Errorf("Failed to save request %s: %s", cb.ID, err.Error())
+		return false, h.responseError(w, *cb, ":x: Failed to save request: "+err.Error())
+	}
+	h.repository.Audit(auditEventApproved, *cb, interactionUser(message), "")
+	logger.Infof("Request %s has been approved by %s (%d/%d)", cb.ID, message.User.Name, len(cb.Approvers), h.requiredApprovals(cb.Action))
+	return false, h.response(w, *cb)
+}
+
+// interactionUser returns the user who acted on the interaction.
+func interactionUser(message slack.InteractionCallback) User {
+	return User{ID: message.User.ID, Name: message.User.Name}
+}
+
+// approverNames returns the linkable names of the approvers.
+func approverNames(approvers []User) string {
+	names := make([]string, len(approvers))
+	for i, v := range approvers {
+		names[i] = WrapUserNameInLink(v.Name)
+	}
+	return strings.Join(names, ", ")
 }
 
 //
-func (h InteractionHandler) handleInviteSelectOrganization(w http.ResponseWriter, message slack.InteractionCallback) error {
-	cb, ok := h.repository.Callbacks().Get(message.CallbackID)
+func (h InteractionHandler) handleInviteSelectOrganization(w http.ResponseWriter, message slack.InteractionCallback, action *slack.BlockAction) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	cb, ok := h.repository.Callbacks().Get(action.BlockID)
 	if !ok {
-		text := ":x: Request has expired: " + message.CallbackID
-		return h.responseError(w, message.OriginalMessage, text)
+		return h.responseExpired(w, message, action.BlockID)
 	}
-	original := message.OriginalMessage
 	if cb.OwnerUser.ID != message.User.ID {
 		text := fmt.Sprintf(":warning: %s does not have select organization permission", WrapUserNameInLink(message.User.Name))
-		return h.responseHint(w, original, text)
+		return h.responseHint(w, cb, text)
 	}
-	cb.Organization = message.ActionCallback.AttachmentActions[0].SelectedOptions[0].Value
+	cb.Organization = action.SelectedOption.Value
 	if cb.Organization == "" {
 		text := ":warning: organization is required"
-		return h.responseHint(w, original, text)
+		return h.responseHint(w, cb, text)
 	}
-	h.repository.Callbacks().Set(cb)
+	cb.Stages = []Stage{inviteConfirmStage(cb)}
+	if err := h.repository.Callbacks().Set(cb); err != nil {
+		logger.Errorf("Failed to save request %s: %s", cb.ID, err.Error())
+		text := ":x: Failed to save request: " + err.Error()
+		return h.responseError(w, cb, text)
+	}
+	h.repository.Audit(auditEventOrganizationSelected, cb, interactionUser(message), "")
+	return h.response(w, cb)
+}
+
+// inviteConfirmStage returns the stage to confirm the invitation request whose organization is selected.
+func inviteConfirmStage(cb Callback) Stage {
 	texts := []string{
 		"Requester: " + WrapUserNameInLink(cb.OwnerUser.Name),
 		"招待メール送信先: " + cb.Value,
 		"対象者の所属組織: " + cb.Organization,
 	}
-	original.Attachments = []slack.Attachment{
-		{
-			Title:      DateTimePrefix() + "Confirm",
-			Text:       "アカウント招待申請の内容を確認してください\n" + WrapTextsInCodeBlock(texts),
-			Color:      ColorCodeBlue,
-			CallbackID: cb.ID,
-			Actions: []slack.AttachmentAction{
-				{
-					Name:  actionInviteConfirm,
-					Text:  "OK, invite",
-					Type:  "button",
-					Style: "primary",
-				},
-				{
-					Name:  actionCancel,
-					Text:  "Cancel",
-					Type:  "button",
-					Style: "danger",
-				},
+	if cb.Reason != "" {
+		texts = append(texts, "申請理由: "+cb.Reason)
+	}
+	if cb.ExpireDate != "" {
+		texts = append(texts, "利用期限: "+cb.ExpireDate)
+	}
+	if cb.Reinvite {
+		texts = append(texts, "承諾待ちの招待を取り消して再送します")
+	}
+	return Stage{
+		Title:  DateTimePrefix() + "Confirm",
+		Text:   "アカウント招待申請の内容を確認してください\n" + WrapTextsInCodeBlock(texts),
+		Status: StageStatusPending,
+		Footer: "Request ID: " + cb.ShortID(),
+		Actions: []StageAction{
+			{
+				Name:  actionInviteConfirm,
+				Text:  "OK, invite",
+				Style: "primary",
+			},
+			{
+				Name:  actionCancel,
+				Text:  "Cancel",
+				Style: "danger",
 			},
 		},
 	}
-	return h.response(w, &original)
 }
 
 //
-func (h InteractionHandler) handleInviteApprove(w http.ResponseWriter, message slack.InteractionCallback) error {
+func (h InteractionHandler) handleInviteApprove(w http.ResponseWriter, message slack.InteractionCallback, action *slack.BlockAction) error {
 
 	// Check
-	cb, ok := h.repository.Callbacks().Get(message.CallbackID)
+	cb, ok := h.repository.Callbacks().Get(action.BlockID)
 	if !ok {
-		text := ":x: Request has expired: " + message.CallbackID
-		return h.responseError(w, message.OriginalMessage, text)
+		return h.responseExpired(w, message, action.BlockID)
 	}
-	original := message.OriginalMessage
 	if !h.repository.IsAdminUserID(message.User.ID) {
 		text := fmt.Sprintf(":warning: %s does not have approve permission", WrapUserNameInLink(message.User.Name))
-		return h.responseHint(w, original, text)
+		return h.responseHint(w, cb, text)
 	}
-	text := fmt.Sprintf(":white_check_mark: %s approved the request", WrapUserNameInLink(message.User.Name))
-	if err := h.responseSuccess(w, original, text); err != nil {
+	if done, err := h.approve(w, message, &cb); err != nil || !done {
+		return err
+	}
+	text := fmt.Sprintf(":white_check_mark: %s approved the request", approverNames(cb.Approvers))
+	if err := h.responseSuccess(w, cb, text); err != nil {
 		return fmt.Errorf("failed to write message: %s", err.Error())
 	}
 
 	// interactive message は 3 秒以内に応答する必要があるため、メイン処理は非同期で行う
+	h.wg.Add(1)
 	go func() {
-		logger.Infof("Starting invite account for %s", cb.Value)
-		original.Attachments = append(original.Attachments, slack.Attachment{
-			Color: ColorCodeBlue,
-			Title: DateTimePrefix() + "Execute",
-			Text:  ":car: Starting invite account ...",
+		defer h.wg.Done()
+		logger.Infof("Starting invite account for %s (reinvite: %t)", cb.Value, cb.Reinvite)
+		text := ":car: Starting invite account ..."
+		if cb.Reinvite {
+			text = ":car: Starting reinvite account, the pending invitation is revoked and re-issued ..."
+		}
+		cb.Stages = append(cb.Stages, Stage{
+			Title:  DateTimePrefix() + "Execute",
+			Text:   text,
+			Status: StageStatusPending,
 		})
-		h.slackClient.UpdateMessage(message.Channel.ID, message.MessageTs, slack.MsgOptionAttachments(original.Attachments...))
-		if err := h.esaClient.InviteAccount(cb.Value); err != nil {
+		h.updateMessage(cb) // ignore update error
+
+		// invite the all emails in one request, not to leave the request partially invited
+		emails := strings.Split(cb.Value, ",")
+		var err error
+		if cb.Reinvite {
+			err = h.esaClient.ReinviteAccountContext(h.ctx, cb.Value) // the reinvite request has only one email
+		} else {
+			err = h.esaClient.InviteAccountsContext(h.ctx, emails)
+		}
+		if err != nil {
 			logger.Errorf("Failed to invite account for %s: %s", cb.Value, err.Error())
-			h.setErrorToLastAttachment(original.Attachments, fmt.Sprintf(":x: Failed to invite account for %s: %s", WrapTextInInlineCodeBlock(cb.Value), err.Error()))
-			h.slackClient.UpdateMessage(message.Channel.ID, message.MessageTs, slack.MsgOptionAttachments(original.Attachments...))
+			h.repository.Audit(auditEventFailed, cb, interactionUser(message), err.Error())
+			setLastStage(cb.Stages, StageStatusError, fmt.Sprintf(":x: Failed to invite account for %s: %s", WrapTextInInlineCodeBlock(cb.Value), describeEsaError(err)))
+			h.updateMessage(cb) // ignore update error
 			return
 		}
 		logger.Infof("Invitation email has been sent to %s", cb.Value)
-		h.setSuccessToLastAttachment(original.Attachments, ":+1: 招待メールを確認し 72 時間以内にアカウント登録を行なってください")
-		h.slackClient.UpdateMessage(message.Channel.ID, message.MessageTs, slack.MsgOptionAttachments(original.Attachments...))
+		for _, email := range emails {
+			approved := ApprovedInvitation{
+				Email:        email,
+				Organization: cb.Organization,
+				ExpireDate:   cb.ExpireDate,
+				RequestUser:  cb.OwnerUser,
+				ApproveUser:  interactionUser(message),
+				ApprovedAt:   time.Now(),
+			}
+			if err := h.repository.Invitations().Set(approved); err != nil {
+				logger.Errorf("Failed to save approved invitation for %s: %s", email, err.Error())
+			}
+			h.repository.RecordInvitedMember(email, cb.Organization, cb.ExpireDate, cb.OwnerUser)
+		}
+		h.repository.Audit(auditEventExecuted, cb, interactionUser(message), "")
+		setLastStage(cb.Stages, StageStatusSuccess, ":+1: 招待メールを確認し 72 時間以内にアカウント登録を行なってください")
+		h.updateMessage(cb) // ignore update error
 	}()
 	return nil
 }
 
 //
-func (h InteractionHandler) handleDeleteApprove(w http.ResponseWriter, message slack.InteractionCallback) error {
+func (h InteractionHandler) handleDeleteApprove(w http.ResponseWriter, message slack.InteractionCallback, action *slack.BlockAction) error {
 
 	// Check
-	cb, ok := h.repository.Callbacks().Get(message.CallbackID)
+	cb, ok := h.repository.Callbacks().Get(action.BlockID)
 	if !ok {
-		text := ":x: Request has expired: " + message.CallbackID
-		return h.responseError(w, message.OriginalMessage, text)
+		return h.responseExpired(w, message, action.BlockID)
 	}
-	original := message.OriginalMessage
 	if !h.repository.IsAdminUserID(message.User.ID) {
 		text := fmt.Sprintf(":warning: %s does not have approve permission", WrapUserNameInLink(message.User.Name))
-		return h.responseHint(w, original, text)
+		return h.responseHint(w, cb, text)
+	}
+	if done, err := h.approve(w, message, &cb); err != nil || !done {
+		return err
 	}
-	text := fmt.Sprintf(":white_check_mark: %s approved the request", WrapUserNameInLink(message.User.Name))
-	if err := h.responseSuccess(w, original, text); err != nil {
+	text := fmt.Sprintf(":white_check_mark: %s approved the request", approverNames(cb.Approvers))
+	if err := h.responseSuccess(w, cb, text); err != nil {
 		return fmt.Errorf("failed to write message: %s", err.Error())
 	}
 
 	// interactive message は 3 秒以内に応答する必要があるため、メイン処理は非同期で行う
+	h.wg.Add(1)
 	go func() {
-		logger.Infof("Starting invite account for %s", cb.Value)
-		original.Attachments = append(original.Attachments, slack.Attachment{
-			Color: ColorCodeBlue,
-			Title: DateTimePrefix() + "Execute",
-			Text:  ":car: Starting delete account ...",
+		defer h.wg.Done()
+		logger.Infof("Starting delete account for %s (reason: %s)", cb.Value, cb.Reason)
+		cb.Stages = append(cb.Stages, Stage{
+			Title:  DateTimePrefix() + "Execute",
+			Text:   ":car: Starting delete account ...",
+			Status: StageStatusPending,
 		})
-		h.slackClient.UpdateMessage(message.Channel.ID, message.MessageTs, slack.MsgOptionAttachments(original.Attachments...))
-		if err := h.esaClient.DeleteAccount(cb.Value); err != nil {
+		h.updateMessage(cb) // ignore update error
+		if err := h.esaClient.DeleteAccountContext(h.ctx, cb.Value); err != nil {
 			logger.Errorf("Failed to delete account %s: %s", cb.Value, err.Error())
-			h.setErrorToLastAttachment(original.Attachments, fmt.Sprintf(":x: Failed to delete account %s: %s", WrapTextInInlineCodeBlock(cb.Value), err.Error()))
-			h.slackClient.UpdateMessage(message.Channel.ID, message.MessageTs, slack.MsgOptionAttachments(original.Attachments...))
+			h.repository.Audit(auditEventFailed, cb, interactionUser(message), err.Error())
+			setLastStage(cb.Stages, StageStatusError, fmt.Sprintf(":x: Failed to delete account %s: %s", WrapTextInInlineCodeBlock(cb.Value), describeEsaError(err)))
+			h.updateMessage(cb) // ignore update error
 			return
 		}
 		logger.Infof("Account %s has been deleted", cb.Value)
+		h.repository.RecordDeletedMember(cb.Value)
+		h.repository.Audit(auditEventExecuted, cb, interactionUser(message), "")
 		results := []string{
 			fmt.Sprintf("対象アカウント %s を削除しました", cb.Value),
 			fmt.Sprintf("- https://%s.esa.io/team?keyword=%s", h.esaClient.GetTeamName(), cb.Value),
 		}
-		h.setSuccessToLastAttachment(original.Attachments, fmt.Sprintf(":+1: Account has been deleted\n%s", WrapTextsInCodeBlock(results)))
-		h.slackClient.UpdateMessage(message.Channel.ID, message.MessageTs, slack.MsgOptionAttachments(original.Attachments...))
+		setLastStage(cb.Stages, StageStatusSuccess, fmt.Sprintf(":+1: Account has been deleted\n%s", WrapTextsInCodeBlock(results)))
+		h.updateMessage(cb) // ignore update error
 	}()
 	return nil
 }
 
 //
-func (h InteractionHandler) handleCleanupApprove(w http.ResponseWriter, message slack.InteractionCallback) error {
+func (h InteractionHandler) handleRevokeApprove(w http.ResponseWriter, message slack.InteractionCallback, action *slack.BlockAction) error {
 
 	// Check
-	cb, ok := h.repository.Callbacks().Get(message.CallbackID)
+	cb, ok := h.repository.Callbacks().Get(action.BlockID)
 	if !ok {
-		text := ":x: Request has expired: " + message.CallbackID
-		return h.responseError(w, message.OriginalMessage, text)
+		return h.responseExpired(w, message, action.BlockID)
 	}
-	original := message.OriginalMessage
 	if !h.repository.IsAdminUserID(message.User.ID) {
 		text := fmt.Sprintf(":warning: %s does not have approve permission", WrapUserNameInLink(message.User.Name))
-		return h.responseHint(w, original, text)
+		return h.responseHint(w, cb, text)
 	}
-	text := fmt.Sprintf(":white_check_mark: %s approved the request", WrapUserNameInLink(message.User.Name))
-	if err := h.responseSuccess(w, original, text); err != nil {
+	if done, err := h.approve(w, message, &cb); err != nil || !done {
+		return err
+	}
+	text := fmt.Sprintf(":white_check_mark: %s approved the request", approverNames(cb.Approvers))
+	if err := h.responseSuccess(w, cb, text); err != nil {
 		return fmt.Errorf("failed to write message: %s", err.Error())
 	}
 
 	// interactive message は 3 秒以内に応答する必要があるため、メイン処理は非同期で行う
+	h.wg.Add(1)
 	go func() {
-		logger.Infof("Starting delete expired account (%s)", cb.Value)
-		original.Attachments = append(original.Attachments, slack.Attachment{
-			Color: ColorCodeBlue,
-			Title: DateTimePrefix() + "Execute",
-			Text:  ":car: Starting delete expired account ...",
+		defer h.wg.Done()
+		logger.Infof("Starting revoke invitation for %s", cb.Value)
+		cb.Stages = append(cb.Stages, Stage{
+			Title:  DateTimePrefix() + "Execute",
+			Text:   ":car: Starting revoke invitation ...",
+			Status: StageStatusPending,
 		})
-		h.slackClient.UpdateMessage(message.Channel.ID, message.MessageTs, slack.MsgOptionAttachments(original.Attachments...))
+		h.updateMessage(cb) // ignore update error
+		invitation, err := h.esaClient.FindInvitation(h.ctx, cb.Value)
+		if err == nil && invitation == nil {
+			err = fmt.Errorf("pending invitation is not found, it may have been accepted or expired")
+		}
+		if err == nil {
+			err = h.esaClient.DeleteInvitationContext(h.ctx, invitation.Code)
+		}
+		if err != nil {
+			logger.Errorf("Failed to revoke invitation for %s: %s", cb.Value, err.Error())
+			h.repository.Audit(auditEventFailed, cb, interactionUser(message), err.Error())
+			setLastStage(cb.Stages, StageStatusError, fmt.Sprintf(":x: Failed to revoke invitation for %s: %s", WrapTextInInlineCodeBlock(cb.Value), describeEsaError(err)))
+			h.updateMessage(cb) // ignore update error
+			return
+		}
+		logger.Infof("Invitation for %s has been revoked", cb.Value)
+		if err := h.repository.Invitations().Delete(cb.Value); err != nil { // the revoked invitation must be approved again
+			logger.Errorf("Failed to delete approved invitation for %s: %s", cb.Value, err.Error())
+		}
+		h.repository.Audit(auditEventExecuted, cb, interactionUser(message), "")
+		setLastStage(cb.Stages, StageStatusSuccess, fmt.Sprintf(":+1: Invitation for %s has been revoked", WrapTextInInlineCodeBlock(cb.Value)))
+		h.updateMessage(cb) // ignore update error
+	}()
+	return nil
+}
+
+//
+func (h InteractionHandler) handleCleanupApprove(w http.ResponseWriter, message slack.InteractionCallback, action *slack.BlockAction) error {
+
+	// Check
+	cb, ok := h.repository.Callbacks().Get(action.BlockID)
+	if !ok {
+		return h.responseExpired(w, message, action.BlockID)
+	}
+	if !h.repository.IsAdminUserID(message.User.ID) {
+		text := fmt.Sprintf(":warning: %s does not have approve permission", WrapUserNameInLink(message.User.Name))
+		return h.responseHint(w, cb, text)
+	}
+	if done, err := h.approve(w, message, &cb); err != nil || !done {
+		return err
+	}
+	text := fmt.Sprintf(":white_check_mark: %s approved the request", approverNames(cb.Approvers))
+	if err := h.responseSuccess(w, cb, text); err != nil {
+		return fmt.Errorf("failed to write message: %s", err.Error())
+	}
+
+	// interactive message は 3 秒以内に応答する必要があるため、メイン処理は非同期で行う
+	h.wg.Add(1)
+	go func() {
+		defer h.wg.Done()
+		logger.Infof("Starting delete expired account (%s) (reason: %s)", cb.Value, cb.Reason)
+		cb.Stages = append(cb.Stages, Stage{
+			Title:  DateTimePrefix() + "Execute",
+			Text:   ":car: Starting delete expired account ...",
+			Status: StageStatusPending,
+		})
+		h.updateMessage(cb) // ignore update error
 		targets := strings.Split(cb.Value, ",")
 		results := make([]string, 0, len(targets)+1)
 		results = append(results, fmt.Sprintf("期限切れアカウント (%d件) を削除しました", len(targets)))
 		for _, target := range targets {
 			logger.Infof("Try to delete expired account (%s)", target)
-			if err := h.esaClient.DeleteAccount(target); err != nil {
+			if err := h.esaClient.DeleteAccountContext(h.ctx, target); IsNotFound(err) {
+				logger.Warningf("Expired account %s has already been deleted: %s", target, err.Error())
+				h.repository.RecordDeletedMember(target)
+				results = append(results, fmt.Sprintf("- (already deleted) https://%s.esa.io/team?keyword=%s", h.esaClient.GetTeamName(), target))
+				continue
+			} else if err != nil {
 				logger.Errorf("Failed to delete expired account %s: %s", target, err.Error())
-				h.setErrorToLastAttachment(original.Attachments, fmt.Sprintf(":x: Failed to delete expired account %s: %s", WrapTextInInlineCodeBlock(target), err.Error()))
-				h.slackClient.UpdateMessage(message.Channel.ID, message.MessageTs, slack.MsgOptionAttachments(original.Attachments...))
+				h.repository.Audit(auditEventFailed, cb, interactionUser(message), target+": "+err.Error())
+				setLastStage(cb.Stages, StageStatusError, fmt.Sprintf(":x: Failed to delete expired account %s: %s", WrapTextInInlineCodeBlock(target), describeEsaError(err)))
+				h.updateMessage(cb) // ignore update error
 				return
 			}
+			h.repository.RecordDeletedMember(target)
 			results = append(results, fmt.Sprintf("- https://%s.esa.io/team?keyword=%s", h.esaClient.GetTeamName(), target))
+			if rateLimit, ok := h.esaClient.RateLimit(); ok {
+				logger.Debugf("esa api quota: remaining=%d, reset=%s", rateLimit.Remaining, rateLimit.Reset.In(timeZone))
+			}
 		}
 		logger.Infof("Expired account has been deleted (%s)", cb.Value)
-		h.setSuccessToLastAttachment(original.Attachments, fmt.Sprintf(":+1: Expired account has been deleted\n%s", WrapTextsInCodeBlock(results)))
-		h.slackClient.UpdateMessage(message.Channel.ID, message.MessageTs, slack.MsgOptionAttachments(original.Attachments...))
+		h.repository.Audit(auditEventExecuted, cb, interactionUser(message), "")
+		setLastStage(cb.Stages, StageStatusSuccess, fmt.Sprintf(":+1: Expired account has been deleted\n%s", WrapTextsInCodeBlock(results)))
+		h.updateMessage(cb) // ignore update error
 	}()
 	return nil
 }
 
-//
-func (h InteractionHandler) response(w http.ResponseWriter, msg *slack.Message) error {
-	w.Header().Add("Content-type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	msg.ReplaceOriginal = true
-	return json.NewEncoder(w).Encode(msg)
+// describeEsaError returns the error message with a hint to resolve it.
+func describeEsaError(err error) string {
+	switch {
+	case IsNotFound(err):
+		return err.Error() + "\n:bulb: 指定したアカウントは存在しないか、既に削除されています"
+	case IsRateLimited(err):
+		return err.Error() + "\n:bulb: esa API の利用制限を超過しました、15 分ほど時間をおいて再度申請してください"
+	case IsUnauthorized(err):
+		return err.Error() + "\n:bulb: ESA_TOKEN が無効か、チームの Owner 権限を持っていません"
+	default:
+		return err.Error()
+	}
 }
 
-//
-func (h InteractionHandler) responseHint(w http.ResponseWriter, msg slack.Message, text string) error {
-	in := msg.Attachments
-	if last := len(in) - 1; last >= 0 {
-		in[last].Color = ColorCodeOrange
-		in[last].Fields = append(in[last].Fields, slack.AttachmentField{Value: text})
+// deleteCallback removes the finished request, to not handle it again or as expired.
+func (h InteractionHandler) deleteCallback(cb Callback) {
+	if err := h.repository.Callbacks().Delete(cb.ID); err != nil {
+		logger.Errorf("Failed to delete request %s: %s", cb.ID, err.Error())
 	}
-	return h.response(w, &msg)
 }
 
-//
-func (h InteractionHandler) responseWarning(w http.ResponseWriter, msg slack.Message, text string) error {
-	h.setWarningToLastAttachment(msg.Attachments, text)
-	return h.response(w, &msg)
+// updateMessage updates the request message rendered from the stages of the callback.
+func (h InteractionHandler) updateMessage(cb Callback) error {
+	if _, _, _, err := h.slackClient.UpdateMessage(cb.ChannelID, cb.MessageTs, stageMessageOptions(cb.ID, cb.Stages)...); err != nil {
+		logger.Errorf("Failed to update message of request %s: %s", cb.ID, err.Error())
+		return err
+	}
+	return nil
 }
 
-//
-func (h InteractionHandler) responseError(w http.ResponseWriter, msg slack.Message, text string) error {
-	h.setErrorToLastAttachment(msg.Attachments, text)
-	return h.response(w, &msg)
+// response updates the request message, since slack does not replace the message by the response of block actions.
+func (h InteractionHandler) response(w http.ResponseWriter, cb Callback) error {
+	if err := h.updateMessage(cb); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return err
+	}
+	w.WriteHeader(http.StatusOK)
+	return nil
 }
 
-//
-func (h InteractionHandler) responseSuccess(w http.ResponseWriter, msg slack.Message, text string) error {
-	h.setSuccessToLastAttachment(msg.Attachments, text)
-	return h.response(w, &msg)
+// responseExpired tells the user that the request has expired, the expired message has been updated by the sweeper.
+func (h InteractionHandler) responseExpired(w http.ResponseWriter, message slack.InteractionCallback, callbackID string) error {
+	w.WriteHeader(http.StatusOK)
+	text := ":x: Request has expired: " + ShortCallbackID(callbackID)
+	if _, err := h.slackClient.PostEphemeral(message.Channel.ID, message.User.ID, slack.MsgOptionText(text, false)); err != nil {
+		return fmt.Errorf("failed to post message: %s", err)
+	}
+	return nil
 }
 
-//
-func (h InteractionHandler) setToLastAttachment(in []slack.Attachment, color string, text string) {
-	if last := len(in) - 1; last >= 0 {
-		in[last].Color = color
-		in[last].Actions = []slack.AttachmentAction{}
-		in[last].Fields = []slack.AttachmentField{}
-		if text != "" {
-			in[last].Text = text
-		}
-	}
+// responseHint shows the hint with keeping the actions, the hint is not saved to show it only once.
+func (h InteractionHandler) responseHint(w http.ResponseWriter, cb Callback, text string) error {
+	cb.Stages = addNoteToLastStage(cb.Stages, text)
+	return h.response(w, cb)
 }
 
 //
-func (h InteractionHandler) setErrorToLastAttachment(in []slack.Attachment, text string) {
-	h.setToLastAttachment(in, ColorCodeRed, text)
+func (h InteractionHandler) responseWarning(w http.ResponseWriter, cb Callback, text string) error {
+	setLastStage(cb.Stages, StageStatusWarning, text)
+	return h.response(w, cb)
 }
 
 //
-func (h InteractionHandler) setWarningToLastAttachment(in []slack.Attachment, text string) {
-	h.setToLastAttachment(in, ColorCodeYellow, text)
+func (h InteractionHandler) responseError(w http.ResponseWriter, cb Callback, text string) error {
+	setLastStage(cb.Stages, StageStatusError, text)
+	return h.response(w, cb)
 }
 
 //
-func (h InteractionHandler) setSuccessToLastAttachment(in []slack.Attachment, text string) {
-	h.setToLastAttachment(in, ColorCodeGreen, text)
+func (h InteractionHandler) responseSuccess(w http.ResponseWriter, cb Callback, text string) error {
+	setLastStage(cb.Stages, StageStatusSuccess, text)
+	return h.response(w, cb)
 }
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nlopes/slack"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInteractionHandlerMalformedRequest(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		body         string
+		expectStatus int
+	}{
+		{
+			body:         "",
+			expectStatus: http.StatusBadRequest,
+		},
+		{
+			body:         "a",
+			expectStatus: http.StatusBadRequest,
+		},
+		{
+			body:         "payload=%zz",
+			expectStatus: http.StatusBadRequest,
+		},
+		{
+			body:         "payload=" + url.QueryEscape(`{"type":`),
+			expectStatus: http.StatusBadRequest,
+		},
+		{
+			body:         "payload=" + url.QueryEscape(`{"type":"interactive_message","channel":{"id":"C0001"},"actions":[{"name":"inviteConfirm"}]}`),
+			expectStatus: http.StatusBadRequest,
+		},
+		{
+			body:         "payload=" + url.QueryEscape(`{"type":"block_actions","channel":{"id":"C0001"},"actions":[]}`),
+			expectStatus: http.StatusBadRequest,
+		},
+		{
+			body:         "payload=" + url.QueryEscape(`{"type":"block_actions","channel":{"id":"C0001"},"actions":[{"type":"button","block_id":"0123","action_id":"unknown"}]}`),
+			expectStatus: http.StatusBadRequest,
+		},
+		{
+			body:         "payload=" + strings.Repeat("a", maxRequestBodySize),
+			expectStatus: http.StatusBadRequest,
+		},
+	}
+	handler := InteractionHandler{channelID: "C0001"}
+	for _, tt := range tests {
+		t.Run("", func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/interaction", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			assert.Equal(t, tt.expectStatus, rec.Code)
+		})
+	}
+}
+
+func TestInteractionHandlerReviewText(t *testing.T) {
+	t.Parallel()
+	handler := InteractionHandler{
+		adminGroupID: "S0001",
+		quorum:       map[string]int{requestActionCleanup: 2},
+		fourEyes:     []string{requestActionDelete},
+	}
+	assert.Equal(t, 1, handler.requiredApprovals(requestActionInvite))
+	assert.Equal(t, 2, handler.requiredApprovals(requestActionCleanup))
+	assert.Equal(t, ":pray: 管理者 <!subteam^S0001> の承認が必要です", handler.reviewText(Callback{Action: requestActionInvite}))
+	assert.Equal(t, ":pray: 管理者 <!subteam^S0001> の承認が必要です (申請者自身は承認できません)", handler.reviewText(Callback{Action: requestActionDelete}))
+	cb := Callback{
+		Action:    requestActionCleanup,
+		Approvers: []User{{ID: "U0001", Name: "foo"}},
+	}
+	assert.Equal(t, ":pray: 管理者 <!subteam^S0001> のうち 2 名の承認が必要です\n:white_check_mark: Approved (1/2): <@foo>", handler.reviewText(cb))
+}
+
+// newTestInteractionHandler returns the handler with a fake slack server, whose admins are U0001, U0002 and U0003.
+func newTestInteractionHandler(t *testing.T, quorum map[string]int, fourEyes []string) (InteractionHandler, func()) {
+	server := newTestSlackServer()
+	client := server.SlackClient()
+	repository, err := NewRepository(client, NewCallbackMap(time.Hour), NewInvitationMap(), NewMemberMap(), NewAuditList(), []string{"U0001", "U0002", "U0003"}, "", nil, nil)
+	if err != nil {
+		server.Close()
+		t.Fatal(err)
+	}
+	return InteractionHandler{
+		mu:          &sync.Mutex{},
+		slackClient: client,
+		repository:  repository,
+		channelID:   "C0001",
+		adminIDs:    []string{"U0001", "U0002", "U0003"},
+		quorum:      quorum,
+		fourEyes:    fourEyes,
+	}, server.Close
+}
+
+// newTestInteraction returns the interaction of the user in the channel.
+func newTestInteraction(userID string) slack.InteractionCallback {
+	message := slack.InteractionCallback{User: slack.User{ID: userID, Name: "name-" + userID}}
+	message.Channel.ID = "C0001"
+	return message
+}
+
+// newTestCallback saves the pending request of the action by U0001.
+func newTestCallback(t *testing.T, h InteractionHandler, action string) Callback {
+	cb := Callback{
+		ID:        h.repository.Callbacks().GenerateID(),
+		Action:    action,
+		Value:     "foo",
+		OwnerUser: User{ID: "U0001", Name: "name-U0001"},
+		ChannelID: "C0001",
+		MessageTs: "1.0",
+		CreatedAt: time.Now(),
+		Stages:    []Stage{h.reviewStage(Callback{Action: action}, actionDeleteApprove)},
+	}
+	if err := h.repository.Callbacks().Set(cb); err != nil {
+		t.Fatal(err)
+	}
+	return cb
+}
+
+func TestInteractionHandlerApproveQuorum(t *testing.T) {
+	t.Parallel()
+	h, closer := newTestInteractionHandler(t, map[string]int{requestActionCleanup: 2}, nil)
+	defer closer()
+	cb := newTestCallback(t, h, requestActionCleanup)
+
+	// the first approval does not reach the quorum
+	done, err := h.approve(httptest.NewRecorder(), newTestInteraction("U0002"), &cb)
+	assert.NoError(t, err)
+	assert.False(t, done)
+	current, ok := h.repository.Callbacks().Get(cb.ID)
+	assert.True(t, ok)
+	assert.Equal(t, []User{{ID: "U0002", Name: "name-U0002"}}, current.Approvers)
+
+	// the same admin is not counted twice
+	done, err = h.approve(httptest.NewRecorder(), newTestInteraction("U0002"), &cb)
+	assert.NoError(t, err)
+	assert.False(t, done)
+	current, ok = h.repository.Callbacks().Get(cb.ID)
+	assert.True(t, ok)
+	assert.Len(t, current.Approvers, 1)
+
+	// another admin reaches the quorum
+	done, err = h.approve(httptest.NewRecorder(), newTestInteraction("U0003"), &cb)
+	assert.NoError(t, err)
+	assert.True(t, done)
+	assert.Len(t, cb.Approvers, 2)
+	_, ok = h.repository.Callbacks().Get(cb.ID)
+	assert.False(t, ok)
+	records, err := h.repository.Audits().Query(AuditFilter{CallbackID: cb.ID, Event: auditEventApproved})
+	assert.NoError(t, err)
+	assert.Len(t, records, 2)
+}
+
+func TestInteractionHandlerApproveFourEyes(t *testing.T) {
+	t.Parallel()
+	h, closer := newTestInteractionHandler(t, nil, []string{requestActionDelete})
+	defer closer()
+
+	// the requester cannot approve own request
+	cb := newTestCallback(t, h, requestActionDelete)
+	done, err := h.approve(httptest.NewRecorder(), newTestInteraction("U0001"), &cb)
+	assert.NoError(t, err)
+	assert.False(t, done)
+	current, ok := h.repository.Callbacks().Get(cb.ID)
+	assert.True(t, ok)
+	assert.Empty(t, current.Approvers)
+
+	// another admin can approve it
+	done, err = h.approve(httptest.NewRecorder(), newTestInteraction("U0002"), &cb)
+	assert.NoError(t, err)
+	assert.True(t, done)
+
+	// the requester can approve own request of the action which is not in the four eyes actions
+	cb = newTestCallback(t, h, requestActionCleanup)
+	done, err = h.approve(httptest.NewRecorder(), newTestInteraction("U0001"), &cb)
+	assert.NoError(t, err)
+	assert.True(t, done)
+}
+
+func TestInteractionHandlerApproveRejected(t *testing.T) {
+	t.Parallel()
+	h, closer := newTestInteractionHandler(t, map[string]int{requestActionCleanup: 2}, nil)
+	defer closer()
+	cb := newTestCallback(t, h, requestActionCleanup)
+	stale := cb
+	assert.NoError(t, h.handleReject(httptest.NewRecorder(), newTestInteraction("U0003"), &slack.BlockAction{BlockID: cb.ID}))
+
+	// the approval after the rejection does not revive the request
+	done, err := h.approve(httptest.NewRecorder(), newTestInteraction("U0002"), &stale)
+	assert.NoError(t, err)
+	assert.False(t, done)
+	_, ok := h.repository.Callbacks().Get(cb.ID)
+	assert.False(t, ok)
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	// maxHistoryEntries is the upper limit of the requests to show in the history.
+	maxHistoryEntries = 20
+)
+
+// historyEntry is the summary of the lifecycle events of a request.
+type historyEntry struct {
+	CallbackID   string
+	Action       string
+	Target       string
+	Requester    User
+	Organization string
+	ExpireDate   string
+	RequestedAt  time.Time
+	Approvers    []string
+	Outcome      string
+	OutcomeAt    time.Time
+}
+
+//
+func (s *MessageListener) handleHistory(cmd Command) error {
+	if err := s.requireAdmin(cmd); err != nil {
+		return err
+	}
+	if len(cmd.Args) != 1 || cmd.Args[0] == "" {
+		return fmt.Errorf("invalid query: %s", WrapTextInInlineCodeBlock(cmd.Prefix+" history [Email|ScreenName|@User]"))
+	}
+	filter := parseHistoryQuery(cmd.Args[0])
+	records, err := s.repository.Audits().Query(filter)
+	if err != nil {
+		return fmt.Errorf("failed to query audit records: %s", err.Error())
+	}
+	entries := buildHistory(records)
+	if len(entries) == 0 {
+		ret := "No history for " + WrapTextInInlineCodeBlock(cmd.Args[0])
+		return s.reply(cmd, ret)
+	}
+	total := len(entries)
+	if total > maxHistoryEntries {
+		entries = entries[total-maxHistoryEntries:]
+	}
+	messages := make([]string, len(entries))
+	for i, v := range entries {
+		messages[i] = v.String()
+	}
+	ret := fmt.Sprintf("History (%d):\n", total)
+	if total > len(entries) {
+		ret = fmt.Sprintf("History (latest %d of %d):\n", len(entries), total)
+	}
+	ret += WrapTextsInCodeBlock(messages)
+	return s.reply(cmd, ret)
+}
+
+// parseHistoryQuery returns the filter of the audit records from the query,
+// which is an email, a screen name, a user mention or a user name prefixed with @.
+func parseHistoryQuery(query string) AuditFilter {
+	query = RemoveMailtoMeta(query)
+	switch {
+	case strings.HasPrefix(query, "<@") && strings.HasSuffix(query, ">"):
+		return AuditFilter{UserID: strings.Split(strings.TrimSuffix(query[2:], ">"), "|")[0]}
+	case strings.HasPrefix(query, "@"):
+		return AuditFilter{UserName: query[1:]}
+	default:
+		return AuditFilter{Target: query}
+	}
+}
+
+// buildHistory summarizes the audit records for each request in order of the requested time.
+func buildHistory(records []AuditRecord) []*historyEntry {
+	var entries []*historyEntry
+	index := map[string]*historyEntry{}
+	for _, r := range records {
+		entry, ok := index[r.CallbackID]
+		if !ok || r.CallbackID == "" { // the reinvitation has no callback
+			entry = &historyEntry{
+				CallbackID:  r.CallbackID,
+				RequestedAt: r.Time,
+			}
+			entries = append(entries, entry)
+			if r.CallbackID != "" {
+				index[r.CallbackID] = entry
+			}
+		}
+		if r.Action != "" {
+			entry.Action = r.Action
+		}
+		if r.Target != "" {
+			entry.Target = r.Target
+		}
+		if r.Requester.Name != "" {
+			entry.Requester = r.Requester
+		}
+		if r.Organization != "" {
+			entry.Organization = r.Organization
+		}
+		if r.ExpireDate != "" {
+			entry.ExpireDate = r.ExpireDate
+		}
+		switch r.Event {
+		case auditEventApproved:
+			entry.Approvers = append(entry.Approvers, r.Actor.Name)
+		case auditEventRejected, auditEventCanceled, auditEventExpired, auditEventExecuted, auditEventFailed:
+			entry.Outcome = r.Event
+			entry.OutcomeAt = r.Time
+			if r.Event != auditEventExpired {
+				entry.Outcome += " by @" + r.Actor.Name
+			}
+		}
+	}
+	return entries
+}
+
+// String returns the entry in a line, it uses plain text to not notify users.
+func (e historyEntry) String() string {
+	id := "-"
+	if e.CallbackID != "" {
+		id = ShortCallbackID(e.CallbackID)
+	}
+	text := fmt.Sprintf("- %s (%s) %s %s: requester=@%s", id, e.RequestedAt.In(timeZone).Format("2006/01/02 15:04"), e.Action, e.Target, e.Requester.Name)
+	if e.Organization != "" {
+		text += ", organization=" + e.Organization
+	}
+	if e.ExpireDate != "" {
+		text += ", expire=" + e.ExpireDate
+	}
+	if len(e.Approvers) > 0 {
+		text += ", approvers=@" + strings.Join(e.Approvers, ",@")
+	}
+	if e.Outcome == "" {
+		return text + ", result=pending"
+	}
+	return text + fmt.Sprintf(", result=%s (%s)", e.Outcome, e.OutcomeAt.In(timeZone).Format("2006/01/02 15:04"))
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseHistoryQuery(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		query  string
+		expect AuditFilter
+	}{
+		{query: "foo@example.com", expect: AuditFilter{Target: "foo@example.com"}},
+		{query: "<mailto:foo@example.com|foo@example.com>", expect: AuditFilter{Target: "foo@example.com"}},
+		{query: "foo", expect: AuditFilter{Target: "foo"}},
+		{query: "<@U0001>", expect: AuditFilter{UserID: "U0001"}},
+		{query: "<@U0001|foo>", expect: AuditFilter{UserID: "U0001"}},
+		{query: "@foo", expect: AuditFilter{UserName: "foo"}},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.expect, parseHistoryQuery(tt.query), tt.query)
+	}
+}
+
+func TestBuildHistory(t *testing.T) {
+	t.Parallel()
+	requester := User{ID: "U0001", Name: "foo"}
+	approver := User{ID: "U0002", Name: "bar"}
+	at := time.Date(2020, 4, 1, 1, 0, 0, 0, time.UTC)
+	invite := Callback{ID: "0123456789abcdef", Action: requestActionInvite, Value: "baz@example.com", OwnerUser: requester, ExpireDate: "2020-09-30"}
+	selected := invite
+	selected.Organization = "Other"
+	deletion := Callback{ID: "fedcba9876543210", Action: requestActionDelete, Value: "baz", OwnerUser: approver}
+	records := []AuditRecord{
+		NewAuditRecord(auditEventRequested, invite, requester),
+		NewAuditRecord(auditEventOrganizationSelected, selected, requester),
+		NewAuditRecord(auditEventApproved, selected, approver),
+		NewAuditRecord(auditEventRequested, deletion, approver),
+		NewAuditRecord(auditEventExecuted, selected, approver),
+		NewAuditRecord(auditEventExecuted, Callback{Action: requestActionInvite, Value: "baz@example.com", OwnerUser: requester}, requester),
+	}
+	for i := range records {
+		records[i].Time = at.Add(time.Duration(i) * time.Hour)
+	}
+	entries := buildHistory(records)
+	if assert.Len(t, entries, 3) {
+		assert.Equal(t, "- 01234567 (2020/04/01 10:00) invite baz@example.com: requester=@foo, organization=Other, expire=2020-09-30, approvers=@bar, result=executed by @bar (2020/04/01 14:00)", entries[0].String())
+		assert.Equal(t, "- FEDCBA98 (2020/04/01 13:00) delete baz: requester=@bar, result=pending", entries[1].String())
+		assert.Equal(t, "- - (2020/04/01 15:00) invite baz@example.com: requester=@foo, result=executed by @foo (2020/04/01 15:00)", entries[2].String())
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// InvitationStore stores the invitations approved by admins, to re-issue them without another approval.
+type InvitationStore interface {
+	Set(value ApprovedInvitation) error
+	Get(email string) (ApprovedInvitation, bool)
+	Delete(email string) error
+}
+
+//
+type ApprovedInvitation struct {
+	Email        string    `json:"email"`
+	Organization string    `json:"organization"`
+	ExpireDate   string    `json:"expire_date,omitempty"`
+	RequestUser  User      `json:"request_user"`
+	ApproveUser  User      `json:"approve_user"`
+	ApprovedAt   time.Time `json:"approved_at"`
+}
+
+//
+func NewInvitationMap() *InvitationMap {
+	return &InvitationMap{
+		values: map[string]ApprovedInvitation{},
+	}
+}
+
+// InvitationMap is an in-memory InvitationStore, the stored values are lost when the bot restarts.
+type InvitationMap struct {
+	mu     sync.Mutex
+	values map[string]ApprovedInvitation
+}
+
+//
+func (im *InvitationMap) Set(value ApprovedInvitation) error {
+	im.mu.Lock()
+	defer im.mu.Unlock()
+	im.values[value.Email] = value
+	return nil
+}
+
+//
+func (im *InvitationMap) Get(email string) (ApprovedInvitation, bool) {
+	im.mu.Lock()
+	defer im.mu.Unlock()
+	value, ok := im.values[email]
+	return value, ok
+}
+
+//
+func (im *InvitationMap) Delete(email string) error {
+	im.mu.Lock()
+	defer im.mu.Unlock()
+	delete(im.values, email)
+	return nil
+}
+
+// FileInvitationStore is an InvitationStore which persists the values to a json file.
+type FileInvitationStore struct {
+	*InvitationMap
+	path string
+}
+
+//
+func NewFileInvitationStore(path string) (*FileInvitationStore, error) {
+	s := &FileInvitationStore{
+		InvitationMap: NewInvitationMap(),
+		path:          path,
+	}
+	var values []ApprovedInvitation
+	if err := readJSONFile(path, &values); err != nil {
+		return nil, err
+	}
+	for _, v := range values {
+		s.values[v.Email] = v
+	}
+	return s, nil
+}
+
+//
+func (s *FileInvitationStore) Set(value ApprovedInvitation) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.values[value.Email] = value
+	return s.save()
+}
+
+//
+func (s *FileInvitationStore) Delete(email string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.values[email]; !ok {
+		return nil
+	}
+	delete(s.values, email)
+	return s.save()
+}
+
+// save writes the all values to the file, the caller must hold the lock.
+func (s *FileInvitationStore) save() error {
+	values := make([]ApprovedInvitation, 0, len(s.values))
+	for _, v := range s.values {
+		values = append(values, v)
+	}
+	sort.Slice(values, func(i, j int) bool {
+		return values[i].ApprovedAt.Before(values[j].ApprovedAt)
+	})
+	return writeJSONFile(s.path, values)
+}
@@ -1,6 +1,8 @@
 package main
 
 import (
+	"bytes"
+	"context"
 	"fmt"
 	"strconv"
 	"strings"
@@ -9,8 +11,19 @@ import (
 	"github.com/nlopes/slack"
 )
 
+// Command is the command requested by the user, via the bot mention or the slash command.
+type Command struct {
+	Name        string
+	Args        []string
+	Prefix      string // e.g. "@bot" or "/esa", to show the usage
+	UserID      string
+	ChannelID   string
+	ResponseURL string // only the slash command has it, to reply ephemerally
+}
+
 //
 type MessageListener struct {
+	ctx                context.Context
 	slackClient        *slack.Client
 	esaClient          *EsaClient
 	repository         *Repository
@@ -18,52 +31,99 @@ type MessageListener struct {
 	botName            string
 	botUsageURL        string
 	accountExpireMonth int
+	reinviteWindow     time.Duration
 	channelID          string
 }
 
-//
+// Run receives the message events via the RTM connection.
 func (s *MessageListener) Run() {
 	rtm := s.slackClient.NewRTM()
 	go rtm.ManageConnection()
-	for msg := range rtm.IncomingEvents {
-		switch ev := msg.Data.(type) {
-		case *slack.MessageEvent:
-			if err := s.handleMessageEvent(ev); err != nil {
-				logger.Errorf("Failed to handle message: %s", err.Error())
-				s.slackClient.PostMessage(s.channelID, slack.MsgOptionText(err.Error(), false)) // ignore post error
+	for {
+		select {
+		case <-s.ctx.Done():
+			if err := rtm.Disconnect(); err != nil {
+				logger.Errorf("Failed to disconnect rtm: %s", err.Error())
+			}
+			return
+		case msg := <-rtm.IncomingEvents:
+			switch ev := msg.Data.(type) {
+			case *slack.MessageEvent:
+				s.dispatch(ev)
 			}
 		}
 	}
 }
 
-// handleMessageEvent handles message events.
-func (s *MessageListener) handleMessageEvent(ev *slack.MessageEvent) error {
+// dispatch handles the message event.
+func (s *MessageListener) dispatch(ev *slack.MessageEvent) {
+	if cmd, ok := s.parseMessageEvent(ev); ok {
+		s.execute(cmd)
+	}
+}
+
+// parseMessageEvent converts the message which mentions the bot in the channel to the command.
+func (s *MessageListener) parseMessageEvent(ev *slack.MessageEvent) (Command, bool) {
 	if ev.Channel != s.channelID {
-		return nil
+		return Command{}, false
 	}
 	if !strings.HasPrefix(ev.Msg.Text, WrapUserNameInLink(s.botID)) {
-		return nil
+		return Command{}, false
 	}
-	cmd := strings.Fields(ev.Msg.Text)
-	if len(cmd) < 2 {
-		return s.handleHelp(ev)
+	cmd := Command{
+		Prefix:    "@" + s.botName,
+		UserID:    ev.User,
+		ChannelID: ev.Channel,
+	}
+	if fields := strings.Fields(ev.Msg.Text); len(fields) >= 2 {
+		cmd.Name = fields[1]
+		cmd.Args = fields[2:]
+	}
+	return cmd, true
+}
+
+// execute handles the command, and reports the error to the user.
+func (s *MessageListener) execute(cmd Command) {
+	if err := s.handleCommand(cmd); err != nil {
+		logger.Errorf("Failed to handle command: %s", err.Error())
+		s.reply(cmd, err.Error()) // ignore post error
 	}
-	switch cmd[1] {
+}
+
+// handleCommand handles the commands.
+func (s *MessageListener) handleCommand(cmd Command) error {
+	switch cmd.Name {
 	case "admins":
-		return s.handleAdmins(ev)
+		return s.handleAdmins(cmd)
+	case "requests":
+		return s.handleRequests(cmd)
 	case "invite":
-		return s.handleInviteAccount(ev)
+		return s.handleInviteAccount(cmd)
+	case "reinvite":
+		return s.handleReinviteAccount(cmd)
 	case "delete":
-		return s.handleDeleteAccount(ev)
+		return s.handleDeleteAccount(cmd)
 	case "cleanup":
-		return s.handleCleanupAccount(ev)
+		return s.handleCleanupAccount(cmd)
+	case "invites":
+		return s.handleInvitations(cmd)
+	case "revoke":
+		return s.handleRevokeInvitation(cmd)
+	case "history":
+		return s.handleHistory(cmd)
+	case "member":
+		return s.handleMember(cmd)
+	case "members":
+		return s.handleMembers(cmd)
+	case "report":
+		return s.handleReport(cmd)
 	default:
-		return s.handleHelp(ev)
+		return s.handleHelp(cmd)
 	}
 }
 
 //
-func (s *MessageListener) handleAdmins(ev *slack.MessageEvent) error {
+func (s *MessageListener) handleAdmins(cmd Command) error {
 	var message string
 	for _, name := range s.repository.GetAdminNames() {
 		if message != "" {
@@ -72,51 +132,92 @@ func (s *MessageListener) handleAdmins(ev *slack.MessageEvent) error {
 		message += "@" + name // use plain text to not notify admins
 	}
 	ret := "Administrators:\n" + WrapTextInCodeBlock(message)
-	if _, _, err := s.slackClient.PostMessage(ev.Channel, slack.MsgOptionAsUser(true), slack.MsgOptionText(ret, false)); err != nil {
-		return fmt.Errorf("failed to post message: %s", err)
+	return s.reply(cmd, ret)
+}
+
+//
+func (s *MessageListener) handleRequests(cmd Command) error {
+	admin := s.repository.IsAdminUserID(cmd.UserID)
+	options := cmd.Args
+	var callbacks []Callback
+	for _, cb := range s.repository.Callbacks().List() {
+		if !admin && cb.OwnerUser.ID != cmd.UserID { // the others' requests are only shown to admins
+			continue
+		}
+		if len(options) == 1 && options[0] != "" && cb.ShortID() != strings.ToUpper(options[0]) {
+			continue
+		}
+		callbacks = append(callbacks, cb)
 	}
-	return nil
+	if len(callbacks) == 0 {
+		ret := "No pending requests"
+		return s.reply(cmd, ret)
+	}
+	messages := make([]string, 0, len(callbacks))
+	for _, cb := range callbacks {
+		text := fmt.Sprintf("- %s (%s): requester=@%s, value=%s", cb.ShortID(), cb.CreatedAt.In(timeZone).Format("01/02 15:04"), cb.OwnerUser.Name, cb.Value) // use plain text to not notify requesters
+		if cb.Organization != "" {
+			text += ", organization=" + cb.Organization
+		}
+		if cb.Reason != "" {
+			text += ", reason=" + cb.Reason
+		}
+		if len(cb.Approvers) > 0 {
+			text += fmt.Sprintf(", approvals=%d", len(cb.Approvers))
+		}
+		messages = append(messages, text)
+	}
+	ret := fmt.Sprintf("Pending requests (%d):\n", len(callbacks)) + WrapTextsInCodeBlock(messages)
+	return s.reply(cmd, ret)
 }
 
 //
-func (s *MessageListener) handleHelp(ev *slack.MessageEvent) error {
+func (s *MessageListener) handleHelp(cmd Command) error {
 	messages := []string{
-		fmt.Sprintf("%-40s : %s", "- @"+s.botName+" help", "利用可能なコマンド一覧を出力します。"),
-		fmt.Sprintf("%-40s : %s", "- @"+s.botName+" admins", "承認を行える管理者一覧を出力します。"),
-		fmt.Sprintf("%-40s : %s", "- @"+s.botName+" invite", "自身の Email 宛に招待リンクを送信します。管理者の承認が必要です。"),
-		fmt.Sprintf("%-40s : %s", "- @"+s.botName+" invite [Email]", "指定した Email 宛に招待リンクを送信します。管理者の承認が必要です。"),
-		fmt.Sprintf("%-40s : %s", "- @"+s.botName+" delete [ScreenName]", "指定した ScreenName のアカウントを削除します。管理者の承認が必要です。"),
-		fmt.Sprintf("%-40s : %s", "- @"+s.botName+" cleanup", fmt.Sprintf("過去 %d ヶ月間アクセスしていないアカウントを削除します。管理者の承認が必要です。", s.accountExpireMonth)),
-		fmt.Sprintf("%-40s : %s", "- @"+s.botName+" cleanup [Month]", "過去 Month ヶ月間アクセスしていないアカウントを削除します。管理者の承認が必要です。"),
+		fmt.Sprintf("%-40s : %s", "- "+cmd.Prefix+" help", "利用可能なコマンド一覧を出力します。"),
+		fmt.Sprintf("%-40s : %s", "- "+cmd.Prefix+" admins", "承認を行える管理者一覧を出力します。"),
+		fmt.Sprintf("%-40s : %s", "- "+cmd.Prefix+" requests", "承認待ちなど処理中の申請一覧を出力します。管理者以外は自身の申請のみ出力します。"),
+		fmt.Sprintf("%-40s : %s", "- "+cmd.Prefix+" requests [RequestID]", "指定した Request ID の申請内容を出力します。管理者以外は自身の申請のみ出力します。"),
+		fmt.Sprintf("%-40s : %s", "- "+cmd.Prefix+" invite", "自身の Email 宛に招待リンクを送信します。管理者の承認が必要です。"),
+		fmt.Sprintf("%-40s : %s", "- "+cmd.Prefix+" invite [Email]", "指定した Email 宛に招待リンクを送信します。管理者の承認が必要です。"),
+		fmt.Sprintf("%-40s : %s", "- "+cmd.Prefix+" reinvite", fmt.Sprintf("自身の Email 宛の招待を再送します。過去 %d 日以内に承認済みで、まだ参加していなければ管理者の承認は不要です。", int(s.reinviteWindow.Hours()/24))),
+		fmt.Sprintf("%-40s : %s", "- "+cmd.Prefix+" invites", "承諾待ちの招待一覧を出力します。管理者のみ利用できます。"),
+		fmt.Sprintf("%-40s : %s", "- "+cmd.Prefix+" revoke [Email]", "指定した Email 宛の承諾待ちの招待を取り消します。管理者の承認が必要です。"),
+		fmt.Sprintf("%-40s : %s", "- "+cmd.Prefix+" delete [ScreenName] [Reason]", "指定した ScreenName のアカウントを削除します。管理者の承認が必要です。"),
+		fmt.Sprintf("%-40s : %s", "- "+cmd.Prefix+" cleanup [Reason]", fmt.Sprintf("過去 %d ヶ月間アクセスしていないアカウントを削除します。管理者の承認が必要です。", s.accountExpireMonth)),
+		fmt.Sprintf("%-40s : %s", "- "+cmd.Prefix+" cleanup [Month] [Reason]", "過去 Month ヶ月間アクセスしていないアカウントを削除します。管理者の承認が必要です。"),
+		fmt.Sprintf("%-40s : %s", "- "+cmd.Prefix+" cleanup --dry-run [--csv] [Month]", "削除せずに削除対象のアカウント一覧を出力します。--csv を指定すると CSV で投稿します。"),
+		fmt.Sprintf("%-40s : %s", "- "+cmd.Prefix+" members", "所属組織ごとのアカウント数を出力します。管理者のみ利用できます。"),
+		fmt.Sprintf("%-40s : %s", "- "+cmd.Prefix+" member [Email|ScreenName]", "指定したアカウントの所属組織と招待日時を出力します。管理者のみ利用できます。"),
+		fmt.Sprintf("%-40s : %s", "- "+cmd.Prefix+" report", "所属組織ごとのアカウント利用状況を CSV で投稿します。管理者のみ利用できます。"),
+		fmt.Sprintf("%-40s : %s", "- "+cmd.Prefix+" history [Email|ScreenName|@User]", "指定した対象者やユーザーに関する過去の申請と承認の履歴を出力します。管理者のみ利用できます。"),
 	}
 	ret := "Available commands:\n" + WrapTextsInCodeBlock(messages)
 	if s.botUsageURL != "" {
 		ret += "\nMore information: " + s.botUsageURL
 	}
-	if _, _, err := s.slackClient.PostMessage(ev.Channel, slack.MsgOptionAsUser(true), slack.MsgOptionText(ret, false)); err != nil {
-		return fmt.Errorf("failed to post message: %s", err)
-	}
-	return nil
+	return s.reply(cmd, ret)
 }
 
 //
-func (s *MessageListener) handleInviteAccount(ev *slack.MessageEvent) error {
+func (s *MessageListener) handleInviteAccount(cmd Command) error {
 
 	//
-	user, err := s.slackClient.GetUserInfo(ev.User)
+	user, err := s.slackClient.GetUserInfo(cmd.UserID)
 	if err != nil {
 		return err
 	}
 	callback := Callback{
-		ID:    s.repository.Callbacks().GenerateID(),
-		Value: user.Profile.Email,
+		Action: requestActionInvite,
+		ID:     s.repository.Callbacks().GenerateID(),
+		Value:  user.Profile.Email,
 		OwnerUser: User{
 			ID:    user.ID,
 			Name:  user.Name,
 			Email: user.Profile.Email,
 		},
 	}
-	options := strings.Fields(ev.Msg.Text)[2:]
+	options := cmd.Args
 	if len(options) == 1 && options[0] != "" {
 		callback.Value = RemoveMailtoMeta(options[0])
 	}
@@ -124,166 +225,365 @@ func (s *MessageListener) handleInviteAccount(ev *slack.MessageEvent) error {
 		return err
 	}
 
-	//
-	s.repository.Callbacks().Set(callback)
-	organizations := s.repository.GetOrganizations()
-	selectOrgOptions := make([]slack.AttachmentActionOption, len(organizations))
-	for i, v := range organizations {
-		selectOrgOptions[i] = slack.AttachmentActionOption{Text: v, Value: v}
-	}
+	return s.postRequest(cmd, callback, s.selectOrganizationStage(callback))
+}
+
+//
+func (s *MessageListener) selectOrganizationStage(callback Callback) Stage {
 	text := "所属組織を選択してください"
 	if callback.Value != callback.OwnerUser.Email {
 		text = "招待するアカウントの所属組織を選択してください"
 	}
-	opts := []slack.MsgOption{
-		slack.MsgOptionAsUser(true),
-		slack.MsgOptionAttachments(slack.Attachment{
-			Title:      DateTimePrefix() + "Select organization",
-			Text:       text,
-			Color:      ColorCodeBlue,
-			CallbackID: callback.ID,
-			Actions: []slack.AttachmentAction{
-				{
-					Name:    actionInviteSelectOrganization,
-					Type:    "select",
-					Options: selectOrgOptions,
-					Style:   "primary",
-				},
-				{
-					Name:  actionCancel,
-					Text:  "Cancel",
-					Type:  "button",
-					Style: "danger",
-				},
+	return Stage{
+		Title:  DateTimePrefix() + "Select organization",
+		Text:   text,
+		Status: StageStatusPending,
+		Footer: "Request ID: " + callback.ShortID(),
+		Actions: []StageAction{
+			{
+				Name:    actionInviteSelectOrganization,
+				Text:    "Select organization",
+				Options: s.repository.GetOrganizations(),
+			},
+			{
+				Name:  actionCancel,
+				Text:  "Cancel",
+				Style: "danger",
 			},
-		}),
+		},
+	}
+}
+
+//
+func (s *MessageListener) handleReinviteAccount(cmd Command) error {
+
+	//
+	user, err := s.slackClient.GetUserInfo(cmd.UserID)
+	if err != nil {
+		return err
+	}
+	email := user.Profile.Email
+	if err := s.repository.ValidEmail(email); err != nil {
+		return err
+	}
+	invitation, err := s.esaClient.FindInvitation(s.ctx, email)
+	if err != nil {
+		return fmt.Errorf("failed to get invitations: %s", err.Error())
+	}
+	approved, ok := s.repository.Invitations().Get(email)
+	member, memberFound := s.repository.Members().Get(email)
+	switch decideReinvite(invitation != nil, approved, ok, member, memberFound, s.reinviteWindow, time.Now()) {
+	case reinviteNotFound:
+		return fmt.Errorf("invitation for %s is not found, use invite command instead", WrapTextInInlineCodeBlock(email))
+	case reinviteApproved:
+		return s.reinviteApprovedAccount(cmd, User{ID: user.ID, Name: user.Name, Email: user.Profile.Email}, approved)
+	}
+
+	// the approval is too old or unknown, so it requires the approval again
+	callback := Callback{
+		Action:       requestActionInvite,
+		ID:           s.repository.Callbacks().GenerateID(),
+		Value:        email,
+		Organization: approved.Organization,
+		Reinvite:     invitation != nil,
+		OwnerUser: User{
+			ID:    user.ID,
+			Name:  user.Name,
+			Email: user.Profile.Email,
+		},
+	}
+	if callback.Organization == "" {
+		return s.postRequest(cmd, callback, s.selectOrganizationStage(callback))
+	}
+	return s.postRequest(cmd, callback, inviteConfirmStage(callback))
+}
+
+const (
+	// reinvite decisions
+	reinviteNotFound = iota // neither invited nor approved, it must be requested by the invite command
+	reinviteApproved        // approved recently and not used yet, it is re-issued without the approval
+	reinviteRequest         // the approval is too old, unknown or already used, it requires the approval again
+)
+
+// decideReinvite decides how to re-issue the invitation, from whether it has the pending invitation,
+// the approved invitation and the member in the registry if found.
+// The approval is reused only while the invitation is pending, or it has expired without joining,
+// not to let the deleted member come back without the approval.
+func decideReinvite(pending bool, approved ApprovedInvitation, found bool, member MemberRecord, memberFound bool, window time.Duration, now time.Time) int {
+	neverJoined := memberFound && member.ScreenName == "" && member.DeletedAt.IsZero()
+	switch {
+	case found && now.Sub(approved.ApprovedAt) <= window && (pending || neverJoined):
+		return reinviteApproved
+	case pending || found:
+		return reinviteRequest
+	default:
+		return reinviteNotFound
+	}
+}
+
+// reinviteApprovedAccount re-issues the invitation without the approval, since it has been approved recently.
+func (s *MessageListener) reinviteApprovedAccount(cmd Command, actor User, approved ApprovedInvitation) error {
+	texts := []string{
+		"Requester: " + WrapUserNameInLink(approved.RequestUser.Name),
+		"招待メール送信先: " + approved.Email,
+		"対象者の所属組織: " + approved.Organization,
+		fmt.Sprintf("Approved: @%s (%s)", approved.ApproveUser.Name, approved.ApprovedAt.In(timeZone).Format("2006/01/02 15:04")),
+	}
+	stages := []Stage{
+		{
+			Title:  DateTimePrefix() + "Execute",
+			Text:   "承認済みの招待を再送します\n" + WrapTextsInCodeBlock(texts),
+			Status: StageStatusPending,
+		},
 	}
-	if _, _, err := s.slackClient.PostMessage(ev.Channel, opts...); err != nil {
+	channelID, ts, err := s.slackClient.PostMessage(s.channelID, append(stageMessageOptions("", stages), slack.MsgOptionAsUser(true))...)
+	if err != nil {
 		return fmt.Errorf("failed to post message: %s", err)
 	}
+	s.acknowledge(cmd, "招待の再送を "+WrapChannelIDInLink(channelID)+" で受け付けました")
+	logger.Infof("Starting reinvite account for %s", approved.Email)
+	cb := Callback{
+		Action:       requestActionInvite,
+		Value:        approved.Email,
+		Organization: approved.Organization,
+		ExpireDate:   approved.ExpireDate,
+		OwnerUser:    approved.RequestUser,
+		Reason:       fmt.Sprintf("reinvite the invitation approved by %s at %s", approved.ApproveUser.Name, approved.ApprovedAt.In(timeZone).Format("2006/01/02 15:04")),
+	}
+	if err := s.esaClient.ReinviteAccountContext(s.ctx, approved.Email); err != nil {
+		logger.Errorf("Failed to reinvite account for %s: %s", approved.Email, err.Error())
+		s.repository.Audit(auditEventFailed, cb, actor, err.Error())
+		setLastStage(stages, StageStatusError, fmt.Sprintf(":x: Failed to reinvite account for %s: %s", WrapTextInInlineCodeBlock(approved.Email), describeEsaError(err)))
+		s.slackClient.UpdateMessage(channelID, ts, stageMessageOptions("", stages)...) // ignore update error
+		return nil
+	}
+	logger.Infof("Invitation email has been resent to %s", approved.Email)
+	s.repository.RecordInvitedMember(approved.Email, approved.Organization, approved.ExpireDate, approved.RequestUser)
+	s.repository.Audit(auditEventExecuted, cb, actor, "")
+	setLastStage(stages, StageStatusSuccess, "")
+	stages = addNoteToLastStage(stages, ":+1: 招待メールを確認し 72 時間以内にアカウント登録を行なってください")
+	s.slackClient.UpdateMessage(channelID, ts, stageMessageOptions("", stages)...) // ignore update error
 	return nil
 }
 
 //
-func (s *MessageListener) handleDeleteAccount(ev *slack.MessageEvent) error {
+func (s *MessageListener) handleDeleteAccount(cmd Command) error {
 
 	//
-	user, err := s.slackClient.GetUserInfo(ev.User)
+	user, err := s.slackClient.GetUserInfo(cmd.UserID)
 	if err != nil {
 		return err
 	}
 	callback := Callback{
-		ID:    s.repository.Callbacks().GenerateID(),
-		Value: user.Profile.Email,
+		Action: requestActionDelete,
+		ID:     s.repository.Callbacks().GenerateID(),
+		Value:  user.Profile.Email,
 		OwnerUser: User{
 			ID:    user.ID,
 			Name:  user.Name,
 			Email: user.Profile.Email,
 		},
 	}
-	options := strings.Fields(ev.Msg.Text)[2:]
-	if len(options) == 1 && options[0] != "" {
+	options := cmd.Args
+	if len(options) >= 1 && options[0] != "" {
 		callback.Value = options[0]
+		callback.Reason = strings.Join(options[1:], " ")
 	}
 	if callback.Value == "" {
 		return fmt.Errorf("invalid ScreenName")
 	}
+	if callback.Reason == "" {
+		return fmt.Errorf("reason is required: %s", WrapTextInInlineCodeBlock(cmd.Prefix+" delete [ScreenName] [Reason]"))
+	}
 
-	//
-	s.repository.Callbacks().Set(callback)
 	texts := []string{
 		"Requester: " + WrapUserNameInLink(user.Name),
 		"対象者のプロフィール: https://" + s.esaClient.GetTeamName() + ".esa.io/members/" + callback.Value,
-	}
-	opts := []slack.MsgOption{
-		slack.MsgOptionAsUser(true),
-		slack.MsgOptionAttachments(slack.Attachment{
-			Title:      DateTimePrefix() + "Confirm",
-			Text:       "アカウント削除申請の内容を確認してください\n" + WrapTextsInCodeBlock(texts),
-			Color:      ColorCodeBlue,
-			CallbackID: callback.ID,
-			Actions: []slack.AttachmentAction{
-				{
-					Name:  actionDeleteConfirm,
-					Text:  "OK, delete",
-					Type:  "button",
-					Style: "primary",
-				},
-				{
-					Name:  actionCancel,
-					Text:  "Cancel",
-					Type:  "button",
-					Style: "danger",
-				},
+		"申請理由: " + callback.Reason,
+	}
+	return s.postRequest(cmd, callback, Stage{
+		Title:  DateTimePrefix() + "Confirm",
+		Text:   "アカウント削除申請の内容を確認してください\n" + WrapTextsInCodeBlock(texts),
+		Status: StageStatusPending,
+		Footer: "Request ID: " + callback.ShortID(),
+		Actions: []StageAction{
+			{
+				Name:  actionDeleteConfirm,
+				Text:  "OK, delete",
+				Style: "primary",
+			},
+			{
+				Name:  actionCancel,
+				Text:  "Cancel",
+				Style: "danger",
 			},
-		}),
+		},
+	})
+}
+
+//
+func (s *MessageListener) handleInvitations(cmd Command) error {
+	if err := s.requireAdmin(cmd); err != nil {
+		return err
 	}
-	if _, _, err := s.slackClient.PostMessage(ev.Channel, opts...); err != nil {
-		return fmt.Errorf("failed to post message: %s", err)
+	invitations, err := s.esaClient.ListAllInvitations(s.ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get invitations: %s", err.Error())
 	}
-	return nil
+	if len(invitations) == 0 {
+		ret := "No pending invitations"
+		return s.reply(cmd, ret)
+	}
+	messages := make([]string, 0, len(invitations))
+	for _, v := range invitations {
+		expires := v.ExpiresAt
+		if t, err := v.ExpiresTime(); err == nil {
+			expires = t.In(timeZone).Format("2006/01/02 15:04")
+		}
+		messages = append(messages, fmt.Sprintf("- %s (expires at %s)", v.Email, expires))
+	}
+	ret := fmt.Sprintf("Pending invitations (%d):\n", len(invitations)) + WrapTextsInCodeBlock(messages)
+	return s.reply(cmd, ret)
 }
 
 //
-func (s *MessageListener) handleCleanupAccount(ev *slack.MessageEvent) error {
+func (s *MessageListener) handleRevokeInvitation(cmd Command) error {
 
 	//
-	user, err := s.slackClient.GetUserInfo(ev.User)
+	user, err := s.slackClient.GetUserInfo(cmd.UserID)
 	if err != nil {
 		return err
 	}
-
-	var targetMonth int
-	options := strings.Fields(ev.Msg.Text)[2:]
+	callback := Callback{
+		Action: requestActionRevoke,
+		ID:     s.repository.Callbacks().GenerateID(),
+		OwnerUser: User{
+			ID:    user.ID,
+			Name:  user.Name,
+			Email: user.Profile.Email,
+		},
+	}
+	options := cmd.Args
 	if len(options) == 1 && options[0] != "" {
-		targetMonth, err = strconv.Atoi(options[0])
-		if err != nil || targetMonth < s.accountExpireMonth {
-			return fmt.Errorf("invalid month, you must be at least %d: %d", s.accountExpireMonth, targetMonth)
+		callback.Value = RemoveMailtoMeta(options[0])
+	}
+	if callback.Value == "" {
+		return fmt.Errorf("invalid Email")
+	}
+	invitation, err := s.esaClient.FindInvitation(s.ctx, callback.Value)
+	if err != nil {
+		return fmt.Errorf("failed to get invitations: %s", err.Error())
+	}
+	if invitation == nil {
+		return fmt.Errorf("pending invitation is not found: %s", WrapTextInInlineCodeBlock(callback.Value))
+	}
+
+	texts := []string{
+		"Requester: " + WrapUserNameInLink(user.Name),
+		"招待の取り消し対象: " + callback.Value,
+		"招待の有効期限: " + invitation.ExpiresAt,
+	}
+	return s.postRequest(cmd, callback, Stage{
+		Title:  DateTimePrefix() + "Confirm",
+		Text:   "招待取り消し申請の内容を確認してください\n" + WrapTextsInCodeBlock(texts),
+		Status: StageStatusPending,
+		Footer: "Request ID: " + callback.ShortID(),
+		Actions: []StageAction{
+			{
+				Name:  actionRevokeConfirm,
+				Text:  "OK, revoke",
+				Style: "primary",
+			},
+			{
+				Name:  actionCancel,
+				Text:  "Cancel",
+				Style: "danger",
+			},
+		},
+	})
+}
+
+// cleanupOptions is the arguments of the cleanup command.
+type cleanupOptions struct {
+	Month  int
+	Reason string
+	DryRun bool
+	CSV    bool
+}
+
+// parseCleanupArgs parses the arguments of the cleanup command, the month must be at least minMonth.
+func parseCleanupArgs(cmd Command, minMonth int) (cleanupOptions, error) {
+	opts := cleanupOptions{Month: minMonth}
+	var options []string
+	for _, v := range cmd.Args {
+		switch v {
+		case "--dry-run":
+			opts.DryRun = true
+		case "--csv":
+			opts.CSV = true
+		default:
+			options = append(options, v)
+		}
+	}
+	if opts.CSV && !opts.DryRun {
+		return opts, fmt.Errorf("--csv is only available with --dry-run: %s", WrapTextInInlineCodeBlock(cmd.Prefix+" cleanup --dry-run --csv [Month]"))
+	}
+	if len(options) >= 1 && isNumber(options[0]) {
+		var err error
+		opts.Month, err = strconv.Atoi(options[0])
+		if err != nil || opts.Month < minMonth {
+			return opts, fmt.Errorf("invalid month, you must be at least %d: %d", minMonth, opts.Month)
 		}
-	} else {
-		targetMonth = s.accountExpireMonth
+		options = options[1:]
+	}
+	opts.Reason = strings.Join(options, " ")
+	if opts.Reason == "" && !opts.DryRun {
+		return opts, fmt.Errorf("reason is required: %s", WrapTextInInlineCodeBlock(cmd.Prefix+" cleanup [Month] [Reason]"))
+	}
+	return opts, nil
+}
+
+//
+func (s *MessageListener) handleCleanupAccount(cmd Command) error {
+
+	//
+	opts, err := parseCleanupArgs(cmd, s.accountExpireMonth)
+	if err != nil {
+		return err
 	}
 
 	// Search
-	res, err := s.esaClient.ListAccount(QueryOptionSort("last_accessed"), QueryOptionOrder("asc"), QueryOptionPerPage(100))
+	expireTime := time.Now().In(timeZone).AddDate(0, -opts.Month, 0)
+	members, err := s.findExpiredAccounts(expireTime)
 	if err != nil {
 		return fmt.Errorf("failed to get the target list that matches the conditions: %s", err.Error())
 	}
-	if len(res.Members) == 0 {
+	if len(members) == 0 {
 		ret := "No accounts matches the conditions"
-		if _, _, err := s.slackClient.PostMessage(ev.Channel, slack.MsgOptionText(ret, false)); err != nil {
-			return err
-		}
-		return nil
+		return s.reply(cmd, ret)
 	}
-	targets := make([]string, 0, len(res.Members))
-	screenNames := make([]string, 0, len(res.Members))
-	expireTime := time.Now().In(timeZone).AddDate(0, -targetMonth, 0)
-	for _, member := range res.Members {
-		t, err := member.LastAccessedTime()
-		if err != nil {
-			logger.Errorf("account %s has unexpected last_accessed_at %s: %s", member.ScreenName, member.LastAccessedAt, err.Error())
-			continue
-		}
-		if !expireTime.After(t) {
-			logger.Debugf("No match condition: screenName=%s, lastAccess=%s, expire=%s", member.ScreenName, t, expireTime)
-			break
-		}
-		screenNames = append(screenNames, member.ScreenName)
-		targets = append(targets, fmt.Sprintf("- (%s) https://%s.esa.io/members/%s", member.LastAccessedAt[:10], s.esaClient.GetTeamName(), member.ScreenName))
+	if opts.DryRun {
+		return s.previewExpiredAccounts(cmd, expireTime, members, opts.CSV)
 	}
-	if len(screenNames) == 0 {
-		ret := "No accounts matches the conditions"
-		if _, _, err := s.slackClient.PostMessage(ev.Channel, slack.MsgOptionText(ret, false)); err != nil {
-			return err
-		}
-		return nil
+	targets := make([]string, len(members))
+	screenNames := make([]string, len(members))
+	for i, member := range members {
+		screenNames[i] = member.ScreenName
+		targets[i] = s.expiredAccountText(member)
+	}
+
+	//
+	user, err := s.slackClient.GetUserInfo(cmd.UserID)
+	if err != nil {
+		return err
 	}
 
 	//
 	callback := Callback{
-		ID:    s.repository.Callbacks().GenerateID(),
-		Value: strings.Join(screenNames, ","),
+		Action: requestActionCleanup,
+		ID:     s.repository.Callbacks().GenerateID(),
+		Value:  strings.Join(screenNames, ","),
+		Reason: opts.Reason,
 		OwnerUser: User{
 			ID:    user.ID,
 			Name:  user.Name,
@@ -294,35 +594,160 @@ func (s *MessageListener) handleCleanupAccount(ev *slack.MessageEvent) error {
 	//
 	texts := []string{
 		"Requester: " + WrapUserNameInLink(user.Name),
+		"申請理由: " + opts.Reason,
 		fmt.Sprintf("Condition: 最終アクセス日時が %s 以前の期限切れアカウント (%d件) を削除します", expireTime.Format("2006/01/02"), len(screenNames)),
 	}
 	texts = append(texts, targets...)
-	s.repository.Callbacks().Set(callback)
-	opts := []slack.MsgOption{
-		slack.MsgOptionAsUser(true),
-		slack.MsgOptionAttachments(slack.Attachment{
-			Title:      DateTimePrefix() + "Confirm",
-			Text:       "期限切れアカウント削除申請の内容を確認してください\n" + WrapTextsInCodeBlock(texts),
-			Color:      ColorCodeBlue,
-			CallbackID: callback.ID,
-			Actions: []slack.AttachmentAction{
-				{
-					Name:  actionCleanupConfirm,
-					Text:  "OK, cleanup",
-					Type:  "button",
-					Style: "primary",
-				},
-				{
-					Name:  actionCancel,
-					Text:  "Cancel",
-					Type:  "button",
-					Style: "danger",
-				},
+	text := "期限切れアカウント削除申請の内容を確認してください\n" + WrapTextsInCodeBlock(texts)
+
+	// the all targets must be shown to the admins, in both the confirm and the execute stages
+	if sections := len(splitMarkdown(text, maxSectionTextLength)); sections*2+cleanupReservedBlocks > maxMessageBlocks {
+		return fmt.Errorf("too many accounts (%d) to show in a request, specify the longer Month or check them by %s", len(screenNames), WrapTextInInlineCodeBlock(cmd.Prefix+" cleanup --dry-run --csv [Month]"))
+	}
+	return s.postRequest(cmd, callback, Stage{
+		Title:  DateTimePrefix() + "Confirm",
+		Text:   text,
+		Status: StageStatusPending,
+		Footer: "Request ID: " + callback.ShortID(),
+		Actions: []StageAction{
+			{
+				Name:  actionCleanupConfirm,
+				Text:  "OK, cleanup",
+				Style: "primary",
 			},
-		}),
+			{
+				Name:  actionCancel,
+				Text:  "Cancel",
+				Style: "danger",
+			},
+		},
+	})
+}
+
+const (
+	// cleanupReservedBlocks is the number of the blocks for the cleanup request other than the targets,
+	// i.e. the footer, the review stage, the dividers and the actions.
+	cleanupReservedBlocks = 8
+)
+
+// findExpiredAccounts returns the accounts which have not accessed since the expire time, in order of the last access.
+func (s *MessageListener) findExpiredAccounts(expireTime time.Time) ([]*Member, error) {
+	var ret []*Member
+	err := s.esaClient.EachAccount(s.ctx, func(member *Member) bool {
+		t, err := member.LastAccessedTime()
+		if err != nil {
+			logger.Errorf("account %s has unexpected last_accessed_at %s: %s", member.ScreenName, member.LastAccessedAt, err.Error())
+			return true
+		}
+		if !expireTime.After(t) {
+			logger.Debugf("No match condition: screenName=%s, lastAccess=%s, expire=%s", member.ScreenName, t, expireTime)
+			return false // members are sorted by last access
+		}
+		ret = append(ret, member)
+		return true
+	}, QueryOptionSort("last_accessed"), QueryOptionOrder("asc"), QueryOptionPerPage(100))
+	if err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+//
+func (s *MessageListener) expiredAccountText(member *Member) string {
+	return fmt.Sprintf("- (%s) https://%s.esa.io/members/%s", member.LastAccessedAt[:10], s.esaClient.GetTeamName(), member.ScreenName)
+}
+
+// previewExpiredAccounts replies the candidates of the cleanup without creating the request,
+// and uploads them as csv to the channel if required.
+func (s *MessageListener) previewExpiredAccounts(cmd Command, expireTime time.Time, members []*Member, withCSV bool) error {
+	condition := fmt.Sprintf("Dry run: 最終アクセス日時が %s 以前の期限切れアカウント (%d件) が削除対象です", expireTime.Format("2006/01/02"), len(members))
+	if !withCSV {
+		targets := make([]string, len(members))
+		for i, member := range members {
+			targets[i] = s.expiredAccountText(member)
+		}
+		return s.reply(cmd, condition+"\n"+WrapTextsInCodeBlock(targets))
+	}
+	var buf bytes.Buffer
+	if err := writeSeatReportCSV(&buf, buildSeatReport(members, s.repository.Members().List(), expireTime)); err != nil {
+		return fmt.Errorf("failed to write candidates: %s", err.Error())
+	}
+	now := time.Now().In(timeZone)
+	_, err := s.slackClient.UploadFileContext(s.ctx, slack.FileUploadParameters{
+		Content:        buf.String(),
+		Filetype:       "csv",
+		Filename:       fmt.Sprintf("esa-cleanup-candidates-%s.csv", now.Format("20060102")),
+		Title:          "Cleanup candidates " + now.Format("2006/01/02"),
+		InitialComment: condition,
+		Channels:       []string{s.channelID},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to upload candidates: %s", err.Error())
+	}
+	s.acknowledge(cmd, "削除対象のアカウント一覧を "+WrapChannelIDInLink(s.channelID)+" に投稿しました")
+	return nil
+}
+
+// postRequest posts the request message to the channel and saves the callback with the posted message location,
+// to update the message when the request expires.
+func (s *MessageListener) postRequest(cmd Command, callback Callback, stages ...Stage) error {
+	callback.Stages = stages
+	channelID, ts, err := s.slackClient.PostMessage(s.channelID, append(stageMessageOptions(callback.ID, callback.Stages), slack.MsgOptionAsUser(true))...)
+	if err != nil {
+		return fmt.Errorf("failed to post message: %s", err)
+	}
+	callback.ChannelID = channelID
+	callback.MessageTs = ts
+	if err := s.repository.Callbacks().Set(callback); err != nil {
+		setLastStage(callback.Stages, StageStatusError, ":x: Failed to save request: "+err.Error())
+		s.slackClient.UpdateMessage(channelID, ts, stageMessageOptions(callback.ID, callback.Stages)...) // ignore update error
+		return fmt.Errorf("failed to save request: %s", err)
+	}
+	s.repository.Audit(auditEventRequested, callback, callback.OwnerUser, "")
+	s.acknowledge(cmd, fmt.Sprintf("申請を %s で受け付けました、以降の操作はこちらで行なってください (Request ID: %s)", WrapChannelIDInLink(channelID), callback.ShortID()))
+	return nil
+}
+
+// reply posts the text to the user, it replies ephemerally to the slash command.
+func (s *MessageListener) reply(cmd Command, text string) error {
+	options := []slack.MsgOption{slack.MsgOptionAsUser(true), slack.MsgOptionText(text, false)}
+	if cmd.ResponseURL != "" {
+		options = append(options, slack.MsgOptionResponseURL(cmd.ResponseURL, slack.ResponseTypeEphemeral))
 	}
-	if _, _, err := s.slackClient.PostMessage(ev.Channel, opts...); err != nil {
+	if _, _, err := s.slackClient.PostMessage(cmd.ChannelID, options...); err != nil {
 		return fmt.Errorf("failed to post message: %s", err)
 	}
 	return nil
 }
+
+// acknowledge tells the user where the request has been posted,
+// if the command was not used in the channel for the approval, e.g. via the slash command or the modal.
+func (s *MessageListener) acknowledge(cmd Command, text string) {
+	if cmd.ChannelID == s.channelID {
+		return
+	}
+	if err := s.reply(cmd, text); err != nil {
+		logger.Errorf("Failed to reply to command: %s", err.Error())
+	}
+}
+
+// requireAdmin returns the error if the user is not an admin, since the command can be used in any channel via the slash command.
+func (s *MessageListener) requireAdmin(cmd Command) error {
+	if !s.repository.IsAdminUserID(cmd.UserID) {
+		return fmt.Errorf("%s command is only available to admins", WrapTextInInlineCodeBlock(cmd.Name))
+	}
+	return nil
+}
+
+// isNumber returns true if the text consists of only digits.
+func isNumber(text string) bool {
+	if text == "" {
+		return false
+	}
+	for _, r := range text {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
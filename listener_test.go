@@ -0,0 +1,70 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDecideReinvite(t *testing.T) {
+	t.Parallel()
+	now := time.Date(2020, 4, 1, 0, 0, 0, 0, time.UTC)
+	window := 30 * 24 * time.Hour
+	recent := ApprovedInvitation{Email: "foo@example.com", ApprovedAt: now.Add(-window)}
+	old := ApprovedInvitation{Email: "foo@example.com", ApprovedAt: now.Add(-window - time.Second)}
+	invited := MemberRecord{Email: "foo@example.com", InvitedAt: recent.ApprovedAt}
+	joined := MemberRecord{Email: "foo@example.com", ScreenName: "foo", InvitedAt: recent.ApprovedAt}
+	deleted := MemberRecord{Email: "foo@example.com", ScreenName: "foo", InvitedAt: recent.ApprovedAt, DeletedAt: now}
+	tests := []struct {
+		name        string
+		pending     bool
+		approved    ApprovedInvitation
+		found       bool
+		member      MemberRecord
+		memberFound bool
+		expect      int
+	}{
+		{name: "unknown", expect: reinviteNotFound},
+		{name: "pending without approval", pending: true, expect: reinviteRequest},
+		{name: "pending with recent approval", pending: true, approved: recent, found: true, member: invited, memberFound: true, expect: reinviteApproved},
+		{name: "expired invitation with recent approval", approved: recent, found: true, member: invited, memberFound: true, expect: reinviteApproved},
+		{name: "pending with old approval", pending: true, approved: old, found: true, member: invited, memberFound: true, expect: reinviteRequest},
+		{name: "expired invitation with old approval", approved: old, found: true, member: invited, memberFound: true, expect: reinviteRequest},
+		{name: "deleted member must re-request", approved: recent, found: true, member: deleted, memberFound: true, expect: reinviteRequest},
+		{name: "joined member must re-request", approved: recent, found: true, member: joined, memberFound: true, expect: reinviteRequest},
+		{name: "unknown member must re-request", approved: recent, found: true, expect: reinviteRequest},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.expect, decideReinvite(tt.pending, tt.approved, tt.found, tt.member, tt.memberFound, window, now), tt.name)
+	}
+}
+
+func TestParseCleanupArgs(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name        string
+		args        []string
+		expect      cleanupOptions
+		expectError bool
+	}{
+		{name: "reason only", args: []string{"expired", "accounts"}, expect: cleanupOptions{Month: 6, Reason: "expired accounts"}},
+		{name: "month and reason", args: []string{"12", "expired"}, expect: cleanupOptions{Month: 12, Reason: "expired"}},
+		{name: "month less than the minimum", args: []string{"3", "expired"}, expectError: true},
+		{name: "reason is required", args: []string{"12"}, expectError: true},
+		{name: "no arguments", expectError: true},
+		{name: "dry run needs no reason", args: []string{"--dry-run"}, expect: cleanupOptions{Month: 6, DryRun: true}},
+		{name: "dry run with month", args: []string{"--dry-run", "12"}, expect: cleanupOptions{Month: 12, DryRun: true}},
+		{name: "csv with dry run", args: []string{"--csv", "--dry-run", "12"}, expect: cleanupOptions{Month: 12, DryRun: true, CSV: true}},
+		{name: "csv without dry run", args: []string{"--csv", "12", "expired"}, expectError: true},
+	}
+	for _, tt := range tests {
+		opts, err := parseCleanupArgs(Command{Name: "cleanup", Args: tt.args, Prefix: "/esa"}, 6)
+		if tt.expectError {
+			assert.Error(t, err, tt.name)
+			continue
+		}
+		assert.NoError(t, err, tt.name)
+		assert.Equal(t, tt.expect, opts, tt.name)
+	}
+}
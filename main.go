@@ -1,8 +1,13 @@
 package main
 
 import (
+	"context"
 	"net/http"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"sync"
+	"syscall"
 	"time"
 
 	"github.com/kelseyhightower/envconfig"
@@ -11,24 +16,41 @@ import (
 
 type (
 	configuration struct {
-		Port               string   `envconfig:"PORT" default:"3000"`
-		ChannelID          string   `envconfig:"CHANNEL_ID" required:"true"`
-		BotID              string   `envconfig:"BOT_ID" required:"true"`
-		BotToken           string   `envconfig:"BOT_TOKEN" required:"true"`
-		BotUsageURL        string   `envconfig:"BOT_USAGE_URL"`
-		VerificationToken  string   `envconfig:"VERIFICATION_TOKEN" required:"true"`
-		AllowEmailDomains  []string `envconfig:"ALLOW_EMAIL_DOMAINS"`
-		EsaToken           string   `envconfig:"ESA_TOKEN" required:"true"`
-		EsaTeamName        string   `envconfig:"ESA_TEAM_NAME" required:"true"`
-		AdminIDs           []string `envconfig:"ADMIN_IDS" required:"true"`
-		AdminGroupID       string   `envconfig:"ADMIN_GROUP_ID"`
-		AccountExpireMonth int      `envconfig:"ACCOUNT_EXPIRE_MONTH" default:"6"`
-		Organizations      []string `envconfig:"ORGANIZATIONS"`
+		Port               string         `envconfig:"PORT" default:"3000"`
+		EventMode          string         `envconfig:"EVENT_MODE" default:"events"`
+		ChannelID          string         `envconfig:"CHANNEL_ID" required:"true"`
+		BotID              string         `envconfig:"BOT_ID" required:"true"`
+		BotToken           string         `envconfig:"BOT_TOKEN" required:"true"`
+		BotUsageURL        string         `envconfig:"BOT_USAGE_URL"`
+		SigningSecret      string         `envconfig:"SIGNING_SECRET" required:"true"`
+		SlashCommand       string         `envconfig:"SLASH_COMMAND" default:"/esa"`
+		AllowEmailDomains  []string       `envconfig:"ALLOW_EMAIL_DOMAINS"`
+		EsaToken           string         `envconfig:"ESA_TOKEN" required:"true"`
+		EsaTeamName        string         `envconfig:"ESA_TEAM_NAME" required:"true"`
+		EsaTimeout         time.Duration  `envconfig:"ESA_TIMEOUT" default:"30s"`
+		EsaMaxRetries      int            `envconfig:"ESA_MAX_RETRIES" default:"3"`
+		AdminIDs           []string       `envconfig:"ADMIN_IDS"`
+		AdminGroupID       string         `envconfig:"ADMIN_GROUP_ID"`
+		AdminGroupApproval bool           `envconfig:"ADMIN_GROUP_APPROVAL"`
+		AdminRefresh       time.Duration  `envconfig:"ADMIN_REFRESH_INTERVAL" default:"10m"`
+		ApprovalQuorum     map[string]int `envconfig:"APPROVAL_QUORUM"`
+		FourEyesActions    []string       `envconfig:"FOUR_EYES_ACTIONS"`
+		AccountExpireMonth int            `envconfig:"ACCOUNT_EXPIRE_MONTH" default:"6"`
+		Organizations      []string       `envconfig:"ORGANIZATIONS"`
+		DataDir            string         `envconfig:"DATA_DIR" default:"data"`
+		RequestTTL         time.Duration  `envconfig:"REQUEST_TTL" default:"168h"`
+		ReinviteWindow     time.Duration  `envconfig:"REINVITE_WINDOW" default:"720h"`
+		MemberSync         time.Duration  `envconfig:"MEMBER_SYNC_INTERVAL" default:"1h"`
+		ReportDay          int            `envconfig:"REPORT_DAY" default:"1"`
 	}
 )
 
 const (
-	envPrefix = ""
+	envPrefix             = ""
+	eventModeEvents       = "events"
+	eventModeRTM          = "rtm"
+	callbackSweepInterval = time.Minute
+	shutdownTimeout       = time.Minute
 )
 
 var (
@@ -47,6 +69,46 @@ func main() {
 	if accountExpireMonth < 1 {
 		accountExpireMonth = 1
 	}
+	if conf.AdminGroupApproval && conf.AdminGroupID == "" {
+		logger.Errorf("ADMIN_GROUP_ID is required to enable ADMIN_GROUP_APPROVAL")
+		os.Exit(1)
+	}
+	if len(conf.AdminIDs) == 0 && !conf.AdminGroupApproval {
+		logger.Errorf("ADMIN_IDS is required unless ADMIN_GROUP_APPROVAL is enabled")
+		os.Exit(1)
+	}
+	for action, quorum := range conf.ApprovalQuorum {
+		switch action {
+		case requestActionInvite, requestActionDelete, requestActionCleanup, requestActionRevoke:
+		default:
+			logger.Errorf("Invalid approval quorum, unknown action: %s", action)
+			os.Exit(1)
+		}
+		if quorum < 1 || (!conf.AdminGroupApproval && quorum > len(conf.AdminIDs)) {
+			logger.Errorf("Invalid approval quorum, it must be between 1 and the number of admins: %s=%d", action, quorum)
+			os.Exit(1)
+		}
+	}
+	for _, action := range conf.FourEyesActions {
+		switch action {
+		case requestActionInvite, requestActionDelete, requestActionCleanup, requestActionRevoke:
+		default:
+			logger.Errorf("Invalid four eyes action: %s", action)
+			os.Exit(1)
+		}
+	}
+	if conf.RequestTTL <= 0 {
+		logger.Errorf("Invalid request ttl, it must be positive: %s", conf.RequestTTL)
+		os.Exit(1)
+	}
+	if conf.ReportDay < 0 || conf.ReportDay > 28 {
+		logger.Errorf("Invalid report day, it must be between 0 and 28: %d", conf.ReportDay)
+		os.Exit(1)
+	}
+	if conf.EventMode != eventModeEvents && conf.EventMode != eventModeRTM {
+		logger.Errorf("Invalid event mode, you must use %s or %s: %s", eventModeEvents, eventModeRTM, conf.EventMode)
+		os.Exit(1)
+	}
 
 	// setup
 	logger.Infof("Start slack event listening")
@@ -56,19 +118,59 @@ func main() {
 		logger.Errorf("Failed to get bot profile: %s", err)
 		os.Exit(1)
 	}
-	esaClient, err := NewEsaClient(conf.EsaTeamName, conf.EsaToken)
+	esaClient, err := NewEsaClient(conf.EsaTeamName, conf.EsaToken, EsaClientOptionTimeout(conf.EsaTimeout), EsaClientOptionRetry(conf.EsaMaxRetries, defaultRetryBaseWait))
 	if err != nil {
 		logger.Errorf("Failed to create esa client: %s", err)
 		os.Exit(1)
 	}
-	repository, err := NewRepository(slackClient, conf.AdminIDs, conf.AllowEmailDomains, conf.Organizations)
+	// the audit log must survive restarts, so the data is always persisted
+	if conf.DataDir == "" {
+		logger.Errorf("DATA_DIR is required to persist the audit log")
+		os.Exit(1)
+	}
+	if err := os.MkdirAll(conf.DataDir, 0700); err != nil {
+		logger.Errorf("Failed to create data directory: %s", err)
+		os.Exit(1)
+	}
+	callbacks, err := NewFileCallbackStore(filepath.Join(conf.DataDir, "callbacks.json"), conf.RequestTTL)
+	if err != nil {
+		logger.Errorf("Failed to create callback store: %s", err)
+		os.Exit(1)
+	}
+	invitations, err := NewFileInvitationStore(filepath.Join(conf.DataDir, "invitations.json"))
+	if err != nil {
+		logger.Errorf("Failed to create invitation store: %s", err)
+		os.Exit(1)
+	}
+	members, err := NewFileMemberStore(filepath.Join(conf.DataDir, "members.json"))
+	if err != nil {
+		logger.Errorf("Failed to create member store: %s", err)
+		os.Exit(1)
+	}
+	audits, err := NewFileAuditStore(filepath.Join(conf.DataDir, "audit.jsonl"))
+	if err != nil {
+		logger.Errorf("Failed to create audit store: %s", err)
+		os.Exit(1)
+	}
+	// the members of the admin user group can approve only if it is enabled, the group is used for the mention by default
+	var approvalGroupID string
+	if conf.AdminGroupApproval {
+		approvalGroupID = conf.AdminGroupID
+	}
+	repository, err := NewRepository(slackClient, callbacks, invitations, members, audits, conf.AdminIDs, approvalGroupID, conf.AllowEmailDomains, conf.Organizations)
 	if err != nil {
 		logger.Errorf("Failed to create repository: %s", err)
 		os.Exit(1)
 	}
 
+	// cancel the running operations on shutdown
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var wg sync.WaitGroup
+
 	// listening slack event and response
 	listener := &MessageListener{
+		ctx:                ctx,
 		esaClient:          esaClient,
 		slackClient:        slackClient,
 		repository:         repository,
@@ -77,27 +179,108 @@ func main() {
 		botName:            bot.Name,
 		botUsageURL:        conf.BotUsageURL,
 		accountExpireMonth: accountExpireMonth,
+		reinviteWindow:     conf.ReinviteWindow,
+	}
+	if conf.EventMode == eventModeRTM {
+		go listener.Run()
+	}
+
+	// update the messages of expired requests
+	sweeper := &CallbackSweeper{
+		ctx:         ctx,
+		slackClient: slackClient,
+		repository:  repository,
+		interval:    callbackSweepInterval,
+	}
+	go sweeper.Run()
+
+	// link the invited members to the esa accounts, to know the organization of each account
+	if conf.MemberSync > 0 {
+		syncer := &MemberSyncer{
+			ctx:        ctx,
+			esaClient:  esaClient,
+			repository: repository,
+			interval:   conf.MemberSync,
+		}
+		go syncer.Run()
+	}
+
+	// post the seat usage report monthly
+	if conf.ReportDay > 0 {
+		scheduler := &ReportScheduler{
+			ctx:      ctx,
+			listener: listener,
+			day:      conf.ReportDay,
+		}
+		go scheduler.Run()
+	}
+
+	// refresh the admins from the admin user group, to grant the approval without restart
+	if conf.AdminGroupApproval && conf.AdminRefresh > 0 {
+		refresher := &AdminRefresher{
+			ctx:        ctx,
+			repository: repository,
+			interval:   conf.AdminRefresh,
+		}
+		go refresher.Run()
 	}
-	go listener.Run()
 
 	// register handler to receive interactive message responses from slack (kicked by user action)
 	auxMux := http.NewServeMux()
-	auxMux.Handle("/interaction", InteractionHandler{
-		esaClient:         esaClient,
-		slackClient:       slackClient,
-		repository:        repository,
-		channelID:         conf.ChannelID,
-		verificationToken: conf.VerificationToken,
-		adminIDs:          conf.AdminIDs,
-		adminGroupID:      conf.AdminGroupID,
-	})
+	auxMux.Handle("/interaction", VerifySignature(conf.SigningSecret, InteractionHandler{
+		ctx:          ctx,
+		wg:           &wg,
+		mu:           &sync.Mutex{},
+		esaClient:    esaClient,
+		slackClient:  slackClient,
+		repository:   repository,
+		viewClient:   NewViewClient(conf.BotToken),
+		listener:     listener,
+		channelID:    conf.ChannelID,
+		adminIDs:     conf.AdminIDs,
+		adminGroupID: conf.AdminGroupID,
+		quorum:       conf.ApprovalQuorum,
+		fourEyes:     conf.FourEyesActions,
+	}))
+	if conf.EventMode == eventModeEvents {
+		auxMux.Handle("/events", VerifySignature(conf.SigningSecret, NewEventHandler(listener)))
+	}
+	auxMux.Handle("/slash", VerifySignature(conf.SigningSecret, NewSlashCommandHandler(listener, conf.SlashCommand)))
 	auxMux.HandleFunc("/alive", func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
 	})
+	server := &http.Server{
+		Addr:    ":" + conf.Port,
+		Handler: auxMux,
+	}
+	go func() {
+		sig := make(chan os.Signal, 1)
+		signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM)
+		<-sig
+		logger.Infof("Shutting down server")
+		shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer shutdownCancel()
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			logger.Errorf("Failed to shutdown server: %s", err)
+		}
+	}()
 	logger.Infof("Server listening on :%s", conf.Port)
-	if err := http.ListenAndServe(":"+conf.Port, auxMux); err != nil {
+	if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 		logger.Errorf("Failed to server listening: %s", err)
 		os.Exit(1)
 	}
+
+	// cancel and wait for the running operations, its progress is reported to slack as failure
+	cancel()
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(shutdownTimeout):
+		logger.Errorf("Timed out waiting for running operations")
+	}
 	os.Exit(0)
 }
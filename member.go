@@ -0,0 +1,319 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MemberStore stores the organization of each esa member, keyed by the email,
+// since esa itself does not know which organization the member belongs to.
+type MemberStore interface {
+	Set(value MemberRecord) error
+	Get(email string) (MemberRecord, bool)
+	List() []MemberRecord
+}
+
+//
+type MemberRecord struct {
+	Email        string    `json:"email"`
+	ScreenName   string    `json:"screen_name,omitempty"` // linked once the invitation is accepted
+	Organization string    `json:"organization,omitempty"`
+	ExpireDate   string    `json:"expire_date,omitempty"` // requested in the invitation form, e.g. 2020-09-30
+	InviteUser   User      `json:"invite_user"`
+	InvitedAt    time.Time `json:"invited_at"`
+	JoinedAt     time.Time `json:"joined_at"`
+	DeletedAt    time.Time `json:"deleted_at"`
+}
+
+// Active returns true if the member occupies a seat of the team.
+func (m MemberRecord) Active() bool {
+	return m.ScreenName != "" && m.DeletedAt.IsZero()
+}
+
+// Status returns the human readable status of the member.
+func (m MemberRecord) Status() string {
+	switch {
+	case !m.DeletedAt.IsZero():
+		return "deleted"
+	case m.ScreenName != "":
+		return "active"
+	default:
+		return "invited"
+	}
+}
+
+//
+func NewMemberMap() *MemberMap {
+	return &MemberMap{
+		values: map[string]MemberRecord{},
+	}
+}
+
+// MemberMap is an in-memory MemberStore, the stored values are lost when the bot restarts.
+type MemberMap struct {
+	mu     sync.Mutex
+	values map[string]MemberRecord
+}
+
+//
+func (mm *MemberMap) Set(value MemberRecord) error {
+	mm.mu.Lock()
+	defer mm.mu.Unlock()
+	mm.values[strings.ToLower(value.Email)] = value
+	return nil
+}
+
+//
+func (mm *MemberMap) Get(email string) (MemberRecord, bool) {
+	mm.mu.Lock()
+	defer mm.mu.Unlock()
+	value, ok := mm.values[strings.ToLower(email)]
+	return value, ok
+}
+
+// List returns the all members in order of the email.
+func (mm *MemberMap) List() []MemberRecord {
+	mm.mu.Lock()
+	defer mm.mu.Unlock()
+	return mm.list()
+}
+
+//
+func (mm *MemberMap) list() []MemberRecord {
+	values := make([]MemberRecord, 0, len(mm.values))
+	for _, v := range mm.values {
+		values = append(values, v)
+	}
+	sort.Slice(values, func(i, j int) bool {
+		return strings.ToLower(values[i].Email) < strings.ToLower(values[j].Email)
+	})
+	return values
+}
+
+// FileMemberStore is a MemberStore which persists the values to a json file.
+type FileMemberStore struct {
+	*MemberMap
+	path string
+}
+
+//
+func NewFileMemberStore(path string) (*FileMemberStore, error) {
+	s := &FileMemberStore{
+		MemberMap: NewMemberMap(),
+		path:      path,
+	}
+	var values []MemberRecord
+	if err := readJSONFile(path, &values); err != nil {
+		return nil, err
+	}
+	for _, v := range values {
+		s.values[strings.ToLower(v.Email)] = v
+	}
+	return s, nil
+}
+
+//
+func (s *FileMemberStore) Set(value MemberRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.values[strings.ToLower(value.Email)] = value
+	return writeJSONFile(s.path, s.list())
+}
+
+// linkMembers links the members to the esa accounts by the email, and returns the changed members.
+// The accounts which are not invited by the bot are added without the organization,
+// and the active members which are not found in the accounts are marked as deleted.
+func linkMembers(members []MemberRecord, accounts []*Member, now time.Time) []MemberRecord {
+	index := make(map[string]MemberRecord, len(members))
+	for _, v := range members {
+		index[strings.ToLower(v.Email)] = v
+	}
+	var changed []MemberRecord
+	found := make(map[string]struct{}, len(accounts))
+	for _, account := range accounts {
+		if account.Email == "" { // the email is only visible to the owners of the team
+			continue
+		}
+		key := strings.ToLower(account.Email)
+		found[key] = struct{}{}
+		member, ok := index[key]
+		if ok && member.Active() && member.ScreenName == account.ScreenName {
+			continue
+		}
+		if !ok {
+			member = MemberRecord{Email: account.Email}
+		}
+		member.ScreenName = account.ScreenName
+		member.DeletedAt = time.Time{}
+		member.JoinedAt = now
+		if t, err := account.JoinedTime(); err == nil {
+			member.JoinedAt = t
+		}
+		changed = append(changed, member)
+	}
+	for key, member := range index {
+		if _, ok := found[key]; ok || !member.Active() {
+			continue
+		}
+		member.DeletedAt = now
+		changed = append(changed, member)
+	}
+	sort.Slice(changed, func(i, j int) bool {
+		return strings.ToLower(changed[i].Email) < strings.ToLower(changed[j].Email)
+	})
+	return changed
+}
+
+// MemberSyncer links the invited members to the esa accounts periodically,
+// since the screen name is decided by the member when the invitation is accepted.
+type MemberSyncer struct {
+	ctx        context.Context
+	esaClient  *EsaClient
+	repository *Repository
+	interval   time.Duration
+}
+
+//
+func (s *MemberSyncer) Run() {
+	s.sync()
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-ticker.C:
+			s.sync()
+		}
+	}
+}
+
+//
+func (s *MemberSyncer) sync() {
+	accounts, err := s.esaClient.ListAllAccounts(s.ctx, QueryOptionPerPage(100))
+	if err != nil {
+		logger.Errorf("Failed to sync members: %s", err.Error())
+		return
+	}
+	for _, member := range linkMembers(s.repository.Members().List(), accounts, time.Now()) {
+		if err := s.repository.Members().Set(member); err != nil {
+			logger.Errorf("Failed to save member %s: %s", member.Email, err.Error())
+			continue
+		}
+		logger.Infof("Member %s has been synced: screenName=%s, status=%s", member.Email, member.ScreenName, member.Status())
+	}
+}
+
+const (
+	// unknownOrganization is the organization of the members who were not invited by the bot.
+	unknownOrganization = "(unknown)"
+)
+
+// countSeats returns the number of the active members for each organization,
+// and the organizations in order of the configured organizations.
+func countSeats(members []MemberRecord, organizations []string) (map[string]int, []string) {
+	counts := map[string]int{}
+	for _, v := range members {
+		if !v.Active() {
+			continue
+		}
+		organization := v.Organization
+		if organization == "" {
+			organization = unknownOrganization
+		}
+		counts[organization]++
+	}
+	keys := make([]string, 0, len(counts))
+	for v := range counts {
+		keys = append(keys, v)
+	}
+	return counts, sortOrganizations(keys, organizations)
+}
+
+// sortOrganizations sorts the organizations in order of the configured organizations,
+// followed by the unknown organization and the organizations which have been removed from the configuration.
+func sortOrganizations(values []string, organizations []string) []string {
+	rank := make(map[string]int, len(organizations)+1)
+	for i, v := range organizations {
+		rank[v] = i
+	}
+	rank[unknownOrganization] = len(organizations)
+	ret := append([]string{}, values...)
+	sort.Slice(ret, func(i, j int) bool {
+		ri, oki := rank[ret[i]]
+		rj, okj := rank[ret[j]]
+		switch {
+		case oki && okj:
+			return ri < rj
+		case oki != okj:
+			return oki
+		default:
+			return ret[i] < ret[j]
+		}
+	})
+	return ret
+}
+
+//
+func (s *MessageListener) handleMembers(cmd Command) error {
+	if err := s.requireAdmin(cmd); err != nil {
+		return err
+	}
+	counts, organizations := countSeats(s.repository.Members().List(), s.repository.GetOrganizations())
+	if len(organizations) == 0 {
+		ret := "No members"
+		return s.reply(cmd, ret)
+	}
+	total := 0
+	messages := make([]string, 0, len(organizations))
+	for _, v := range organizations {
+		total += counts[v]
+		messages = append(messages, fmt.Sprintf("- %s: %d", v, counts[v]))
+	}
+	ret := fmt.Sprintf("Members per organization (%d):\n", total) + WrapTextsInCodeBlock(messages)
+	return s.reply(cmd, ret)
+}
+
+//
+func (s *MessageListener) handleMember(cmd Command) error {
+	if err := s.requireAdmin(cmd); err != nil {
+		return err
+	}
+	if len(cmd.Args) != 1 || cmd.Args[0] == "" {
+		return fmt.Errorf("invalid query: %s", WrapTextInInlineCodeBlock(cmd.Prefix+" member [Email|ScreenName]"))
+	}
+	query := RemoveMailtoMeta(cmd.Args[0])
+	member, ok := s.repository.FindMember(query)
+	if !ok {
+		ret := "Member is not found: " + WrapTextInInlineCodeBlock(query)
+		return s.reply(cmd, ret)
+	}
+	organization := member.Organization
+	if organization == "" {
+		organization = unknownOrganization
+	}
+	texts := []string{
+		"Email: " + member.Email,
+		"ScreenName: " + member.ScreenName,
+		"Organization: " + organization,
+		"Status: " + member.Status(),
+	}
+	if !member.InvitedAt.IsZero() {
+		texts = append(texts, fmt.Sprintf("Invited: %s by @%s", member.InvitedAt.In(timeZone).Format("2006/01/02 15:04"), member.InviteUser.Name)) // use plain text to not notify the requester
+	}
+	if member.ExpireDate != "" {
+		texts = append(texts, "Expire: "+member.ExpireDate)
+	}
+	if !member.JoinedAt.IsZero() {
+		texts = append(texts, "Joined: "+member.JoinedAt.In(timeZone).Format("2006/01/02 15:04"))
+	}
+	if !member.DeletedAt.IsZero() {
+		texts = append(texts, "Deleted: "+member.DeletedAt.In(timeZone).Format("2006/01/02 15:04"))
+	}
+	ret := "Member:\n" + WrapTextsInCodeBlock(texts)
+	return s.reply(cmd, ret)
+}
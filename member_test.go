@@ -0,0 +1,76 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileMemberStore(t *testing.T) {
+	t.Parallel()
+	dir, err := ioutil.TempDir("", "esa-account-bot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "members.json")
+
+	store, err := NewFileMemberStore(path)
+	assert.NoError(t, err)
+	member := MemberRecord{Email: "Foo@example.com", Organization: "Other", ExpireDate: "2020-09-30", InvitedAt: time.Now().Truncate(time.Second).UTC()}
+	assert.NoError(t, store.Set(member))
+
+	// reopen the store as if the bot restarted
+	reopened, err := NewFileMemberStore(path)
+	assert.NoError(t, err)
+	ret, ok := reopened.Get("foo@example.com")
+	assert.True(t, ok)
+	assert.Equal(t, member, ret)
+	assert.Equal(t, []MemberRecord{member}, reopened.List())
+}
+
+func TestLinkMembers(t *testing.T) {
+	t.Parallel()
+	now := time.Date(2020, 4, 1, 0, 0, 0, 0, time.UTC)
+	joined := time.Date(2020, 3, 1, 0, 0, 0, 0, time.UTC)
+	members := []MemberRecord{
+		{Email: "invited@example.com", Organization: "A"},
+		{Email: "pending@example.com", Organization: "A"},
+		{Email: "active@example.com", ScreenName: "active", Organization: "B", JoinedAt: joined},
+		{Email: "left@example.com", ScreenName: "left", Organization: "B", JoinedAt: joined},
+	}
+	accounts := []*Member{
+		{Email: "Invited@example.com", ScreenName: "invited", JoinedAt: "2020-03-01T00:00:00+00:00"},
+		{Email: "active@example.com", ScreenName: "active", JoinedAt: "2020-03-01T00:00:00+00:00"},
+		{Email: "other@example.com", ScreenName: "other", JoinedAt: "2020-03-01T00:00:00+00:00"},
+		{ScreenName: "hidden"},
+	}
+	ret := linkMembers(members, accounts, now)
+	for i := range ret {
+		ret[i].JoinedAt = ret[i].JoinedAt.UTC()
+	}
+	assert.Equal(t, []MemberRecord{
+		{Email: "invited@example.com", ScreenName: "invited", Organization: "A", JoinedAt: joined},
+		{Email: "left@example.com", ScreenName: "left", Organization: "B", JoinedAt: joined, DeletedAt: now},
+		{Email: "other@example.com", ScreenName: "other", JoinedAt: joined},
+	}, ret)
+}
+
+func TestCountSeats(t *testing.T) {
+	t.Parallel()
+	members := []MemberRecord{
+		{Email: "a1@example.com", ScreenName: "a1", Organization: "A"},
+		{Email: "a2@example.com", ScreenName: "a2", Organization: "A"},
+		{Email: "a3@example.com", Organization: "A"},
+		{Email: "b1@example.com", ScreenName: "b1", Organization: "B", DeletedAt: time.Now()},
+		{Email: "c1@example.com", ScreenName: "c1", Organization: "C"},
+		{Email: "x1@example.com", ScreenName: "x1"},
+	}
+	counts, organizations := countSeats(members, []string{"B", "A"})
+	assert.Equal(t, map[string]int{"A": 2, "C": 1, unknownOrganization: 1}, counts)
+	assert.Equal(t, []string{"A", unknownOrganization, "C"}, organizations)
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/nlopes/slack"
+)
+
+const (
+	// callbackIDInvite is the callback id of the invite shortcut and its modal view.
+	callbackIDInvite = "invite"
+
+	// block ids of the invite modal view
+	inviteBlockEmails       = "emails"
+	inviteBlockOrganization = "organization"
+	inviteBlockReason       = "reason"
+	inviteBlockExpireDate   = "expire_date"
+	inviteActionValue       = "value"
+
+	// maxInviteEmails is the upper limit of the emails to invite at once.
+	maxInviteEmails = 20
+)
+
+// handleShortcut opens the modal view for the shortcut.
+func (h InteractionHandler) handleShortcut(w http.ResponseWriter, message slack.InteractionCallback) error {
+	if message.CallbackID != callbackIDInvite {
+		w.WriteHeader(http.StatusBadRequest)
+		return fmt.Errorf("invalid shortcut was submitted: %s", message.CallbackID)
+	}
+	if err := h.viewClient.OpenView(h.ctx, message.TriggerID, h.inviteModalView()); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return err
+	}
+	w.WriteHeader(http.StatusOK)
+	return nil
+}
+
+// inviteModalView returns the modal view to collect the invitation request.
+func (h InteractionHandler) inviteModalView() ModalView {
+	organizations := h.repository.GetOrganizations()
+	options := make([]*slack.OptionBlockObject, len(organizations))
+	for i, v := range organizations {
+		options[i] = slack.NewOptionBlockObject(v, slack.NewTextBlockObject(slack.PlainTextType, v, false, false))
+	}
+	return ModalView{
+		Type:       "modal",
+		CallbackID: callbackIDInvite,
+		Title:      slack.NewTextBlockObject(slack.PlainTextType, "Invite", false, false),
+		Submit:     slack.NewTextBlockObject(slack.PlainTextType, "Request", false, false),
+		Close:      slack.NewTextBlockObject(slack.PlainTextType, "Cancel", false, false),
+		Blocks: []slack.Block{
+			InputBlock{
+				Type:    blockTypeInput,
+				BlockID: inviteBlockEmails,
+				Label:   slack.NewTextBlockObject(slack.PlainTextType, "招待メール送信先", false, false),
+				Element: NewPlainTextInputElement(inviteActionValue, "foo@example.com", true),
+				Hint:    slack.NewTextBlockObject(slack.PlainTextType, fmt.Sprintf("改行またはカンマ区切りで %d 件まで指定できます", maxInviteEmails), false, false),
+			},
+			InputBlock{
+				Type:    blockTypeInput,
+				BlockID: inviteBlockOrganization,
+				Label:   slack.NewTextBlockObject(slack.PlainTextType, "対象者の所属組織", false, false),
+				Element: slack.NewOptionsSelectBlockElement(slack.OptTypeStatic, slack.NewTextBlockObject(slack.PlainTextType, "Select organization", false, false), inviteActionValue, options...),
+			},
+			InputBlock{
+				Type:    blockTypeInput,
+				BlockID: inviteBlockReason,
+				Label:   slack.NewTextBlockObject(slack.PlainTextType, "申請理由", false, false),
+				Element: NewPlainTextInputElement(inviteActionValue, "", true),
+			},
+			InputBlock{
+				Type:     blockTypeInput,
+				BlockID:  inviteBlockExpireDate,
+				Label:    slack.NewTextBlockObject(slack.PlainTextType, "利用期限", false, false),
+				Element:  slack.NewDatePickerBlockElement(inviteActionValue),
+				Optional: true,
+			},
+		},
+	}
+}
+
+// handleViewSubmission validates the submitted invitation request, and posts it to the channel for the approval.
+func (h InteractionHandler) handleViewSubmission(w http.ResponseWriter, body []byte) error {
+	var submission ViewSubmission
+	if err := json.Unmarshal(body, &submission); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return fmt.Errorf("failed to decode view submission: %s", err.Error())
+	}
+	if submission.View.CallbackID != callbackIDInvite {
+		w.WriteHeader(http.StatusBadRequest)
+		return fmt.Errorf("invalid view was submitted: %s", submission.View.CallbackID)
+	}
+	cb, errs := h.parseInviteSubmission(submission)
+	if len(errs) > 0 {
+		w.Header().Add("Content-type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		return json.NewEncoder(w).Encode(map[string]interface{}{
+			"response_action": "errors",
+			"errors":          errs,
+		})
+	}
+
+	// the modal is closed by the empty response, and the request is posted asynchronously
+	w.WriteHeader(http.StatusOK)
+	h.wg.Add(1)
+	go func() {
+		defer h.wg.Done()
+		cmd := Command{
+			UserID:    submission.User.ID,
+			ChannelID: submission.User.ID, // reply to the direct message
+		}
+		if err := h.postInviteRequest(cmd, cb); err != nil {
+			logger.Errorf("Failed to post invitation request: %s", err.Error())
+			h.listener.reply(cmd, err.Error()) // ignore post error
+		}
+	}()
+	return nil
+}
+
+// parseInviteSubmission returns the callback from the submitted view, or the errors for each block.
+func (h InteractionHandler) parseInviteSubmission(submission ViewSubmission) (Callback, map[string]string) {
+	errs := map[string]string{}
+	emails := splitEmails(submission.Value(inviteBlockEmails, inviteActionValue).Value)
+	switch {
+	case len(emails) == 0:
+		errs[inviteBlockEmails] = "招待メール送信先を入力してください"
+	case len(emails) > maxInviteEmails:
+		errs[inviteBlockEmails] = fmt.Sprintf("招待メール送信先は %d 件まで指定できます", maxInviteEmails)
+	default:
+		for _, email := range emails {
+			if err := h.repository.ValidEmail(email); err != nil {
+				errs[inviteBlockEmails] = err.Error()
+				break
+			}
+		}
+	}
+	organization := submission.Value(inviteBlockOrganization, inviteActionValue).SelectedOption.Value
+	valid := false
+	for _, v := range h.repository.GetOrganizations() {
+		if v == organization {
+			valid = true
+			break
+		}
+	}
+	if !valid {
+		errs[inviteBlockOrganization] = "所属組織を選択してください"
+	}
+	reason := strings.TrimSpace(submission.Value(inviteBlockReason, inviteActionValue).Value)
+	if reason == "" {
+		errs[inviteBlockReason] = "申請理由を入力してください"
+	}
+	expireDate := submission.Value(inviteBlockExpireDate, inviteActionValue).SelectedDate
+	if expireDate != "" {
+		t, err := time.ParseInLocation("2006-01-02", expireDate, timeZone)
+		if err != nil || !t.After(time.Now()) {
+			errs[inviteBlockExpireDate] = "利用期限には明日以降の日付を指定してください"
+		}
+	}
+	return Callback{
+		Action:       requestActionInvite,
+		Value:        strings.Join(emails, ","),
+		Organization: organization,
+		Reason:       reason,
+		ExpireDate:   expireDate,
+	}, errs
+}
+
+// postInviteRequest posts the invitation request which has already been confirmed by the modal, so it waits for the approval.
+func (h InteractionHandler) postInviteRequest(cmd Command, cb Callback) error {
+	user, err := h.slackClient.GetUserInfo(cmd.UserID)
+	if err != nil {
+		return err
+	}
+	cb.ID = h.repository.Callbacks().GenerateID()
+	cb.OwnerUser = User{
+		ID:    user.ID,
+		Name:  user.Name,
+		Email: user.Profile.Email,
+	}
+	stages := []Stage{inviteConfirmStage(cb)}
+	setLastStage(stages, StageStatusSuccess, "")
+	stages = append(stages, h.reviewStage(cb, actionInviteApprove))
+	if err := h.listener.postRequest(cmd, cb, stages...); err != nil {
+		return err
+	}
+	h.repository.Audit(auditEventConfirmed, cb, cb.OwnerUser, "")
+	return nil
+}
+
+// splitEmails splits the text by the new lines, spaces or commas, and removes the duplicated emails.
+func splitEmails(text string) []string {
+	fields := strings.FieldsFunc(text, func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\n' || r == '\r' || r == '\t'
+	})
+	ret := make([]string, 0, len(fields))
+	seen := make(map[string]struct{}, len(fields))
+	for _, v := range fields {
+		v = RemoveMailtoMeta(v)
+		if _, ok := seen[v]; ok {
+			continue
+		}
+		seen[v] = struct{}{}
+		ret = append(ret, v)
+	}
+	return ret
+}
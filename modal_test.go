@@ -0,0 +1,51 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSplitEmails(t *testing.T) {
+	t.Parallel()
+	ret := splitEmails("foo@example.com, bar@example.com\n<mailto:baz@example.com|baz@example.com>\nfoo@example.com")
+	assert.Equal(t, []string{"foo@example.com", "bar@example.com", "baz@example.com"}, ret)
+	assert.Empty(t, splitEmails(" \n, "))
+}
+
+func TestParseInviteSubmission(t *testing.T) {
+	t.Parallel()
+	h := InteractionHandler{
+		repository: &Repository{
+			allowEmailDomains: map[string]struct{}{"example.com": {}},
+			organizationList:  []string{"A", "B"},
+		},
+	}
+	tests := []struct {
+		payload      string
+		expectValue  string
+		expectErrors []string
+	}{
+		{
+			payload:     `{"emails":{"value":{"value":"foo@example.com\nbar@example.com"}},"organization":{"value":{"selected_option":{"value":"A"}}},"reason":{"value":{"value":" project "}},"expire_date":{"value":{}}}`,
+			expectValue: "foo@example.com,bar@example.com",
+		},
+		{
+			payload:      `{"emails":{"value":{"value":"foo@example.org"}},"organization":{"value":{"selected_option":{"value":"C"}}},"reason":{"value":{"value":" "}},"expire_date":{"value":{"selected_date":"2000-01-01"}}}`,
+			expectValue:  "foo@example.org",
+			expectErrors: []string{inviteBlockEmails, inviteBlockOrganization, inviteBlockReason, inviteBlockExpireDate},
+		},
+	}
+	for _, tt := range tests {
+		var submission ViewSubmission
+		assert.NoError(t, json.Unmarshal([]byte(`{"view":{"state":{"values":`+tt.payload+`}}}`), &submission))
+		cb, errs := h.parseInviteSubmission(submission)
+		assert.Equal(t, tt.expectValue, cb.Value)
+		keys := make([]string, 0, len(errs))
+		for key := range errs {
+			keys = append(keys, key)
+		}
+		assert.ElementsMatch(t, tt.expectErrors, keys)
+	}
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"github.com/nlopes/slack"
+)
+
+const (
+	// stage statuses
+	StageStatusPending = "pending"
+	StageStatusSuccess = "success"
+	StageStatusWarning = "warning"
+	StageStatusError   = "error"
+
+	// maxSectionTextLength is the upper limit of the text length of the section block.
+	maxSectionTextLength = 3000
+
+	// maxMessageBlocks is the upper limit of the blocks in a message.
+	maxMessageBlocks = 50
+)
+
+// Stage is a step of the request such as Select organization, Confirm, Review and Execute,
+// the request message is rendered from the stages as Block Kit blocks.
+type Stage struct {
+	Title   string        `json:"title"`
+	Text    string        `json:"text"`
+	Status  string        `json:"status"`
+	Notes   []string      `json:"notes,omitempty"`
+	Footer  string        `json:"footer,omitempty"`
+	Actions []StageAction `json:"actions,omitempty"`
+}
+
+// StageAction is a button, or a select menu if it has options.
+type StageAction struct {
+	Name    string   `json:"name"`
+	Text    string   `json:"text"`
+	Style   string   `json:"style,omitempty"`
+	Options []string `json:"options,omitempty"`
+}
+
+// setLastStage updates the status and the text of the last stage, and removes its actions.
+func setLastStage(stages []Stage, status string, text string) {
+	if last := len(stages) - 1; last >= 0 {
+		stages[last].Status = status
+		stages[last].Actions = nil
+		stages[last].Notes = nil
+		if text != "" {
+			stages[last].Text = text
+		}
+	}
+}
+
+// addNoteToLastStage adds the note to the last stage with keeping its actions.
+func addNoteToLastStage(stages []Stage, text string) []Stage {
+	ret := make([]Stage, len(stages))
+	copy(ret, stages)
+	if last := len(ret) - 1; last >= 0 {
+		ret[last].Notes = append(append([]string{}, ret[last].Notes...), text)
+	}
+	return ret
+}
+
+// renderStages renders the stages as Block Kit blocks, the block id of the actions is the callback id.
+func renderStages(callbackID string, stages []Stage) []slack.Block {
+	blocks := make([]slack.Block, 0, len(stages)*4)
+	for i, stage := range stages {
+		if i > 0 {
+			blocks = append(blocks, slack.NewDividerBlock())
+		}
+		text := "*" + stageStatusEmoji(stage.Status) + " " + stage.Title + "*"
+		if stage.Text != "" {
+			text += "\n" + stage.Text
+		}
+		for _, chunk := range splitMarkdown(text, maxSectionTextLength) { // the long text such as the cleanup targets is shown in several sections
+			blocks = append(blocks, slack.NewSectionBlock(slack.NewTextBlockObject(slack.MarkdownType, chunk, false, false), nil, nil))
+		}
+		var elements []slack.MixedElement
+		for _, note := range stage.Notes {
+			elements = append(elements, slack.NewTextBlockObject(slack.MarkdownType, note, false, false))
+		}
+		if stage.Footer != "" {
+			elements = append(elements, slack.NewTextBlockObject(slack.MarkdownType, stage.Footer, false, false))
+		}
+		if len(elements) > 0 {
+			blocks = append(blocks, slack.NewContextBlock("", elements...))
+		}
+		if len(stage.Actions) > 0 {
+			blocks = append(blocks, renderStageActions(callbackID, stage.Actions))
+		}
+	}
+	return blocks
+}
+
+//
+func renderStageActions(callbackID string, actions []StageAction) *slack.ActionBlock {
+	elements := make([]slack.BlockElement, 0, len(actions))
+	for _, action := range actions {
+		text := slack.NewTextBlockObject(slack.PlainTextType, action.Text, false, false)
+		if len(action.Options) > 0 {
+			options := make([]*slack.OptionBlockObject, len(action.Options))
+			for i, v := range action.Options {
+				options[i] = slack.NewOptionBlockObject(v, slack.NewTextBlockObject(slack.PlainTextType, v, false, false))
+			}
+			elements = append(elements, slack.NewOptionsSelectBlockElement(slack.OptTypeStatic, text, action.Name, options...))
+			continue
+		}
+		button := slack.NewButtonBlockElement(action.Name, callbackID, text)
+		button.Style = slack.Style(action.Style)
+		elements = append(elements, button)
+	}
+	return slack.NewActionBlock(callbackID, elements...)
+}
+
+// stageMessageOptions returns the message options to post or update the message rendered from the stages.
+func stageMessageOptions(callbackID string, stages []Stage) []slack.MsgOption {
+	var fallback string
+	if last := len(stages) - 1; last >= 0 {
+		fallback = stages[last].Title
+	}
+	return []slack.MsgOption{
+		slack.MsgOptionText(fallback, false), // used in the notification
+		slack.MsgOptionBlocks(renderStages(callbackID, stages)...),
+	}
+}
+
+//
+func stageStatusEmoji(status string) string {
+	switch status {
+	case StageStatusSuccess:
+		return ":large_green_circle:"
+	case StageStatusWarning:
+		return ":large_yellow_circle:"
+	case StageStatusError:
+		return ":red_circle:"
+	default:
+		return ":large_blue_circle:"
+	}
+}
+
+// splitMarkdown splits the text into the chunks within the max length at the line breaks,
+// the code block is closed at the end of the chunk and reopened at the next chunk.
+func splitMarkdown(text string, max int) []string {
+	if utf8.RuneCountInString(text) <= max {
+		return []string{text}
+	}
+	limit := max - 8 // reserve for closing and reopening the code block
+	var chunks, lines []string
+	size := 0
+	open := false // whether the code block is open at the end of the lines
+	for _, line := range strings.Split(text, "\n") {
+		if utf8.RuneCountInString(line) > limit-4 {
+			line = string([]rune(line)[:limit-8]) + "..."
+		}
+		n := utf8.RuneCountInString(line) + 1
+		if size+n > limit && len(lines) > 0 {
+			chunk := strings.Join(lines, "\n")
+			lines, size = nil, 0
+			if open {
+				chunk += "\n```"
+				lines, size = []string{"```"}, 4
+			}
+			chunks = append(chunks, chunk)
+		}
+		lines = append(lines, line)
+		size += n
+		if strings.Count(line, "```")%2 == 1 {
+			open = !open
+		}
+	}
+	return append(chunks, strings.Join(lines, "\n"))
+}
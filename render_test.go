@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/nlopes/slack"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRenderStages(t *testing.T) {
+	t.Parallel()
+	stages := []Stage{
+		{
+			Title:  "Confirm",
+			Text:   "text",
+			Status: StageStatusPending,
+			Footer: "Request ID: 0123ABCD",
+			Actions: []StageAction{
+				{Name: actionInviteConfirm, Text: "OK, invite", Style: "primary"},
+				{Name: actionCancel, Text: "Cancel", Style: "danger"},
+			},
+		},
+	}
+	setLastStage(stages, StageStatusSuccess, "")
+	stages = append(stages, Stage{
+		Title:  "Select organization",
+		Status: StageStatusPending,
+		Actions: []StageAction{
+			{Name: actionInviteSelectOrganization, Text: "Select organization", Options: []string{"A", "B"}},
+		},
+	})
+	blocks := renderStages("0123abcd", stages)
+	types := make([]slack.MessageBlockType, len(blocks))
+	for i, v := range blocks {
+		types[i] = v.BlockType()
+	}
+	assert.Equal(t, []slack.MessageBlockType{
+		slack.MBTSection, slack.MBTContext, // the actions of the confirmed stage are removed
+		slack.MBTDivider,
+		slack.MBTSection, slack.MBTAction,
+	}, types)
+	assert.Equal(t, "*:large_green_circle: Confirm*\ntext", blocks[0].(*slack.SectionBlock).Text.Text)
+	actions := blocks[4].(*slack.ActionBlock)
+	assert.Equal(t, "0123abcd", actions.BlockID)
+	assert.Equal(t, actionInviteSelectOrganization, actions.Elements.ElementSet[0].(*slack.SelectBlockElement).ActionID)
+}
+
+func TestSplitMarkdown(t *testing.T) {
+	t.Parallel()
+	assert.Equal(t, []string{"text"}, splitMarkdown("text", 10))
+
+	lines := make([]string, 100)
+	for i := range lines {
+		lines[i] = fmt.Sprintf("- target%02d", i)
+	}
+	text := "*Confirm*\n```\n" + strings.Join(lines, "\n") + "\n```"
+	chunks := splitMarkdown(text, 100)
+	assert.True(t, len(chunks) > 1)
+	var all []string
+	for _, chunk := range chunks {
+		assert.True(t, len(chunk) <= 100, chunk)
+		assert.Equal(t, 0, strings.Count(chunk, "```")%2, chunk) // the code block is closed in each chunk
+		for _, line := range strings.Split(chunk, "\n") {
+			if strings.HasPrefix(line, "- target") {
+				all = append(all, line)
+			}
+		}
+	}
+	assert.Equal(t, lines, all) // no target is dropped
+	assert.True(t, strings.HasPrefix(chunks[1], "```\n"))
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/nlopes/slack"
+)
+
+const (
+	// reportHour is the hour to post the monthly report.
+	reportHour = 10
+
+	// seat statuses in the report
+	seatStatusActive   = "active"
+	seatStatusInactive = "inactive"
+)
+
+// SeatReportRow is an esa account with the organization recorded in the member registry.
+type SeatReportRow struct {
+	ScreenName     string
+	Email          string
+	Organization   string
+	Status         string
+	JoinedAt       string
+	LastAccessedAt string
+}
+
+// SeatCount is the number of the accounts of the organization.
+type SeatCount struct {
+	Organization string
+	Active       int
+	Inactive     int
+}
+
+// buildSeatReport returns the accounts with the organization, the accounts which have not accessed since the expire time are inactive.
+func buildSeatReport(accounts []*Member, members []MemberRecord, expireTime time.Time) []SeatReportRow {
+	byEmail := make(map[string]MemberRecord, len(members))
+	byScreenName := make(map[string]MemberRecord, len(members))
+	for _, v := range members {
+		byEmail[strings.ToLower(v.Email)] = v
+		if v.Active() {
+			byScreenName[v.ScreenName] = v
+		}
+	}
+	rows := make([]SeatReportRow, 0, len(accounts))
+	for _, account := range accounts {
+		member, ok := byEmail[strings.ToLower(account.Email)]
+		if !ok || account.Email == "" {
+			member = byScreenName[account.ScreenName]
+		}
+		row := SeatReportRow{
+			ScreenName:     account.ScreenName,
+			Email:          account.Email,
+			Organization:   member.Organization,
+			Status:         seatStatusActive,
+			JoinedAt:       account.JoinedAt,
+			LastAccessedAt: account.LastAccessedAt,
+		}
+		if row.Organization == "" {
+			row.Organization = unknownOrganization
+		}
+		if t, err := account.LastAccessedTime(); err != nil {
+			logger.Errorf("account %s has unexpected last_accessed_at %s: %s", account.ScreenName, account.LastAccessedAt, err.Error()) // count as active, same as cleanup
+		} else if expireTime.After(t) {
+			row.Status = seatStatusInactive
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+// countSeatReport returns the number of the active and inactive accounts for each organization.
+func countSeatReport(rows []SeatReportRow, organizations []string) []SeatCount {
+	counts := map[string]*SeatCount{}
+	var keys []string
+	for _, row := range rows {
+		count, ok := counts[row.Organization]
+		if !ok {
+			count = &SeatCount{Organization: row.Organization}
+			counts[row.Organization] = count
+			keys = append(keys, row.Organization)
+		}
+		if row.Status == seatStatusActive {
+			count.Active++
+		} else {
+			count.Inactive++
+		}
+	}
+	ret := make([]SeatCount, 0, len(keys))
+	for _, v := range sortOrganizations(keys, organizations) {
+		ret = append(ret, *counts[v])
+	}
+	return ret
+}
+
+// writeSeatReportCSV writes the accounts as csv with the header.
+func writeSeatReportCSV(w io.Writer, rows []SeatReportRow) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write([]string{"organization", "screen_name", "email", "status", "joined_at", "last_accessed_at"}); err != nil {
+		return err
+	}
+	for _, row := range rows {
+		if err := writer.Write([]string{row.Organization, row.ScreenName, row.Email, row.Status, row.JoinedAt, row.LastAccessedAt}); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+//
+func (s *MessageListener) handleReport(cmd Command) error {
+	if err := s.requireAdmin(cmd); err != nil {
+		return err
+	}
+	if err := s.postSeatReport(); err != nil {
+		return err
+	}
+	s.acknowledge(cmd, "アカウント利用状況のレポートを "+WrapChannelIDInLink(s.channelID)+" に投稿しました")
+	return nil
+}
+
+// postSeatReport uploads the seat usage report as csv to the channel, with the counts for each organization.
+func (s *MessageListener) postSeatReport() error {
+	accounts, err := s.esaClient.ListAllAccounts(s.ctx, QueryOptionPerPage(100))
+	if err != nil {
+		return fmt.Errorf("failed to get accounts: %s", err.Error())
+	}
+	now := time.Now().In(timeZone)
+	rows := buildSeatReport(accounts, s.repository.Members().List(), now.AddDate(0, -s.accountExpireMonth, 0))
+	var active, inactive int
+	var messages []string
+	for _, v := range countSeatReport(rows, s.repository.GetOrganizations()) {
+		active += v.Active
+		inactive += v.Inactive
+		messages = append(messages, fmt.Sprintf("- %s: active=%d, inactive=%d, total=%d", v.Organization, v.Active, v.Inactive, v.Active+v.Inactive))
+	}
+	summary := fmt.Sprintf("Seat usage report (%s): active=%d, inactive=%d, total=%d\n", now.Format("2006/01/02"), active, inactive, active+inactive)
+	summary += fmt.Sprintf("inactive は過去 %d ヶ月間アクセスしていないアカウントです\n", s.accountExpireMonth)
+	summary += WrapTextsInCodeBlock(messages)
+	var buf bytes.Buffer
+	if err := writeSeatReportCSV(&buf, rows); err != nil {
+		return fmt.Errorf("failed to write report: %s", err.Error())
+	}
+	_, err = s.slackClient.UploadFileContext(s.ctx, slack.FileUploadParameters{
+		Content:        buf.String(),
+		Filetype:       "csv",
+		Filename:       fmt.Sprintf("esa-seats-%s.csv", now.Format("20060102")),
+		Title:          "Seat usage report " + now.Format("2006/01/02"),
+		InitialComment: summary,
+		Channels:       []string{s.channelID},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to upload report: %s", err.Error())
+	}
+	return nil
+}
+
+// nextReportTime returns the next time to post the monthly report on the day of the month.
+func nextReportTime(now time.Time, day int) time.Time {
+	now = now.In(timeZone)
+	next := time.Date(now.Year(), now.Month(), day, reportHour, 0, 0, 0, timeZone)
+	if !next.After(now) {
+		next = next.AddDate(0, 1, 0)
+	}
+	return next
+}
+
+// ReportScheduler posts the seat usage report monthly.
+type ReportScheduler struct {
+	ctx      context.Context
+	listener *MessageListener
+	day      int
+}
+
+//
+func (s *ReportScheduler) Run() {
+	for {
+		timer := time.NewTimer(time.Until(nextReportTime(time.Now(), s.day)))
+		select {
+		case <-s.ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+			logger.Infof("Starting monthly report")
+			if err := s.listener.postSeatReport(); err != nil {
+				logger.Errorf("Failed to post monthly report: %s", err.Error())
+			}
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSeatReport(t *testing.T) {
+	t.Parallel()
+	members := []MemberRecord{
+		{Email: "foo@example.com", Organization: "A"},
+		{Email: "bar@example.com", ScreenName: "bar", Organization: "B"},
+		{Email: "old@example.com", ScreenName: "baz", Organization: "B", DeletedAt: time.Now()},
+	}
+	accounts := []*Member{
+		{ScreenName: "foo", Email: "FOO@example.com", JoinedAt: "2020-01-01T00:00:00+09:00", LastAccessedAt: "2020-03-01T00:00:00+09:00"},
+		{ScreenName: "bar", JoinedAt: "2019-01-01T00:00:00+09:00", LastAccessedAt: "2019-06-01T00:00:00+09:00"},
+		{ScreenName: "baz", Email: "baz@example.com", JoinedAt: "2019-01-01T00:00:00+09:00", LastAccessedAt: "2020-03-01T00:00:00+09:00"},
+	}
+	expireTime := time.Date(2020, 1, 1, 0, 0, 0, 0, timeZone)
+	rows := buildSeatReport(accounts, members, expireTime)
+	assert.Equal(t, []SeatReportRow{
+		{ScreenName: "foo", Email: "FOO@example.com", Organization: "A", Status: seatStatusActive, JoinedAt: "2020-01-01T00:00:00+09:00", LastAccessedAt: "2020-03-01T00:00:00+09:00"},
+		{ScreenName: "bar", Organization: "B", Status: seatStatusInactive, JoinedAt: "2019-01-01T00:00:00+09:00", LastAccessedAt: "2019-06-01T00:00:00+09:00"},
+		{ScreenName: "baz", Email: "baz@example.com", Organization: unknownOrganization, Status: seatStatusActive, JoinedAt: "2019-01-01T00:00:00+09:00", LastAccessedAt: "2020-03-01T00:00:00+09:00"},
+	}, rows)
+	assert.Equal(t, []SeatCount{
+		{Organization: "B", Inactive: 1},
+		{Organization: "A", Active: 1},
+		{Organization: unknownOrganization, Active: 1},
+	}, countSeatReport(rows, []string{"B", "A"}))
+
+	var buf bytes.Buffer
+	assert.NoError(t, writeSeatReportCSV(&buf, rows[:2]))
+	assert.Equal(t, "organization,screen_name,email,status,joined_at,last_accessed_at\n"+
+		"A,foo,FOO@example.com,active,2020-01-01T00:00:00+09:00,2020-03-01T00:00:00+09:00\n"+
+		"B,bar,,inactive,2019-01-01T00:00:00+09:00,2019-06-01T00:00:00+09:00\n", buf.String())
+}
+
+func TestNextReportTime(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		now    time.Time
+		day    int
+		expect time.Time
+	}{
+		{
+			now:    time.Date(2020, 4, 1, 9, 59, 0, 0, timeZone),
+			day:    1,
+			expect: time.Date(2020, 4, 1, 10, 0, 0, 0, timeZone),
+		},
+		{
+			now:    time.Date(2020, 4, 1, 10, 0, 0, 0, timeZone),
+			day:    1,
+			expect: time.Date(2020, 5, 1, 10, 0, 0, 0, timeZone),
+		},
+		{
+			now:    time.Date(2020, 12, 20, 0, 0, 0, 0, timeZone),
+			day:    15,
+			expect: time.Date(2021, 1, 15, 10, 0, 0, 0, timeZone),
+		},
+		{
+			now:    time.Date(2020, 3, 31, 16, 0, 0, 0, time.UTC), // 2020/04/01 01:00 JST
+			day:    1,
+			expect: time.Date(2020, 4, 1, 10, 0, 0, 0, timeZone),
+		},
+	}
+	for _, tt := range tests {
+		assert.True(t, tt.expect.Equal(nextReportTime(tt.now, tt.day)), tt.now.String())
+	}
+}
@@ -14,7 +14,13 @@ import (
 //
 type Repository struct {
 	slackClient       *slack.Client
-	callbacks         *CallbackMap
+	callbacks         CallbackStore
+	invitations       InvitationStore
+	members           MemberStore
+	audits            AuditStore
+	adminIDs          []string
+	adminGroupID      string
+	mu                sync.RWMutex // guards admins
 	admins            map[string]User
 	allowEmailDomains map[string]struct{}
 	organizationList  []string
@@ -22,30 +28,13 @@ type Repository struct {
 
 //
 type User struct {
-	ID    string
-	Name  string
-	Email string
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	Email string `json:"email"`
 }
 
 //
-func NewRepository(slackClient *slack.Client, adminIDs []string, allowEmailDomains []string, organizations []string) (*Repository, error) {
-	admins := make(map[string]User, len(adminIDs))
-	for _, v := range adminIDs {
-		user, err := slackClient.GetUserInfo(v)
-		if err != nil {
-			logger.Errorf("Failed to get admin user profile: %s", err.Error())
-			continue
-		}
-		admin := User{
-			ID:    user.ID,
-			Name:  user.Name,
-			Email: user.Profile.Email,
-		}
-		admins[v] = admin
-	}
-	if len(admins) == 0 {
-		return nil, errors.New("empty admins")
-	}
+func NewRepository(slackClient *slack.Client, callbacks CallbackStore, invitations InvitationStore, members MemberStore, audits AuditStore, adminIDs []string, adminGroupID string, allowEmailDomains []string, organizations []string) (*Repository, error) {
 	if len(organizations) == 0 {
 		organizations = []string{"Other"}
 	}
@@ -53,28 +42,180 @@ func NewRepository(slackClient *slack.Client, adminIDs []string, allowEmailDomai
 	for _, v := range allowEmailDomains {
 		domains[v] = struct{}{}
 	}
-	return &Repository{
-		callbacks:         NewCallbackMap(),
+	r := &Repository{
+		callbacks:         callbacks,
+		invitations:       invitations,
+		members:           members,
+		audits:            audits,
 		slackClient:       slackClient,
-		admins:            admins,
+		adminIDs:          adminIDs,
+		adminGroupID:      adminGroupID,
 		allowEmailDomains: domains,
 		organizationList:  organizations,
-	}, nil
+	}
+	if err := r.RefreshAdmins(); err != nil {
+		if adminGroupID == "" || len(adminIDs) == 0 {
+			return nil, err
+		}
+		// not to stop the bot by the admin user group, e.g. the token lacks the usergroups:read scope
+		logger.Errorf("Failed to resolve admins from the admin user group, only ADMIN_IDS can approve until the next refresh: %s", err.Error())
+		if err := r.setAdmins(adminIDs); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
 }
 
 //
-func (r *Repository) Callbacks() *CallbackMap {
+func (r *Repository) Callbacks() CallbackStore {
 	return r.callbacks
 }
 
+//
+func (r *Repository) Invitations() InvitationStore {
+	return r.invitations
+}
+
+//
+func (r *Repository) Members() MemberStore {
+	return r.members
+}
+
+// RecordInvitedMember records the organization of the invited member, it only logs the failure not to stop the operation.
+func (r *Repository) RecordInvitedMember(email string, organization string, expireDate string, requester User) {
+	member, _ := r.members.Get(email)
+	member.Email = email
+	member.Organization = organization
+	member.ExpireDate = expireDate
+	member.InviteUser = requester
+	member.InvitedAt = time.Now()
+	if !member.Active() { // the screen name is linked again when the invitation is accepted
+		member.ScreenName = ""
+		member.DeletedAt = time.Time{}
+	}
+	if err := r.members.Set(member); err != nil {
+		logger.Errorf("Failed to save member %s: %s", email, err.Error())
+	}
+}
+
+// RecordDeletedMember marks the member as deleted, it only logs the failure not to stop the operation.
+// It also discards the approved invitation, so the deleted member cannot be reinvited without the approval.
+func (r *Repository) RecordDeletedMember(screenName string) {
+	member, ok := r.FindMember(screenName)
+	if !ok {
+		return
+	}
+	if err := r.invitations.Delete(member.Email); err != nil {
+		logger.Errorf("Failed to delete approved invitation for %s: %s", member.Email, err.Error())
+	}
+	if !member.Active() {
+		return
+	}
+	member.DeletedAt = time.Now()
+	if err := r.members.Set(member); err != nil {
+		logger.Errorf("Failed to save member %s: %s", member.Email, err.Error())
+	}
+}
+
+// FindMember returns the member specified by the email or the screen name.
+func (r *Repository) FindMember(query string) (MemberRecord, bool) {
+	if member, ok := r.members.Get(query); ok {
+		return member, true
+	}
+	for _, v := range r.members.List() {
+		if v.ScreenName != "" && strings.EqualFold(v.ScreenName, query) {
+			return v, true
+		}
+	}
+	return MemberRecord{}, false
+}
+
+//
+func (r *Repository) Audits() AuditStore {
+	return r.audits
+}
+
+// Audit records the event of the request, it only logs the failure not to stop the operation.
+func (r *Repository) Audit(event string, cb Callback, actor User, message string) {
+	record := NewAuditRecord(event, cb, actor)
+	record.Message = message
+	if err := r.audits.Append(record); err != nil {
+		logger.Errorf("Failed to append audit record %s of request %s: %s", event, cb.ID, err.Error())
+	}
+}
+
+// RefreshAdmins resolves the admins from ADMIN_IDS and the members of the admin user group.
+// It keeps the current admins if it fails to get the members of the admin user group,
+// but it applies the empty admins if the admin user group becomes empty.
+func (r *Repository) RefreshAdmins() error {
+	ids := append([]string{}, r.adminIDs...)
+	if r.adminGroupID != "" {
+		members, err := r.slackClient.GetUserGroupMembers(r.adminGroupID)
+		if err != nil {
+			return fmt.Errorf("failed to get admin group members: %s", err)
+		}
+		ids = append(ids, members...)
+	}
+	return r.setAdmins(ids)
+}
+
+// setAdmins resolves the profiles of the admins, and replaces the current admins.
+func (r *Repository) setAdmins(ids []string) error {
+	r.mu.RLock()
+	current := r.admins
+	r.mu.RUnlock()
+	admins := make(map[string]User, len(ids))
+	for _, v := range ids {
+		if _, ok := admins[v]; ok {
+			continue
+		}
+		if admin, ok := current[v]; ok {
+			admins[v] = admin
+			continue
+		}
+		user, err := r.slackClient.GetUserInfo(v)
+		if err != nil {
+			logger.Errorf("Failed to get admin user profile: %s", err.Error())
+			continue
+		}
+		admins[v] = User{
+			ID:    user.ID,
+			Name:  user.Name,
+			Email: user.Profile.Email,
+		}
+		if current != nil {
+			logger.Infof("Admin %s has been added", user.Name)
+		}
+	}
+	if len(admins) == 0 && current == nil {
+		return errors.New("empty admins")
+	}
+	for id, admin := range current {
+		if _, ok := admins[id]; !ok {
+			logger.Infof("Admin %s has been removed", admin.Name)
+		}
+	}
+	if len(admins) == 0 { // apply it not to keep the approval rights of the removed admins
+		logger.Warningf("No admins, the requests cannot be approved until an admin is added")
+	}
+	r.mu.Lock()
+	r.admins = admins
+	r.mu.Unlock()
+	return nil
+}
+
 //
 func (r *Repository) IsAdminUserID(userID string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
 	_, ok := r.admins[userID]
 	return ok
 }
 
 //
 func (r *Repository) GetAdminNames() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
 	ret := make([]string, 0, len(r.admins))
 	for _, admin := range r.admins {
 		ret = append(ret, admin.Name)
@@ -110,62 +251,3 @@ func (r *Repository) ValidEmail(email string) error {
 	}
 	return fmt.Errorf("invalid email domain, you must use %s: %s", hint, WrapTextInInlineCodeBlock(email))
 }
-
-//
-func NewCallbackMap() *CallbackMap {
-	return &CallbackMap{
-		values:  map[string]Callback{},
-		timeNow: time.Now,
-	}
-}
-
-//
-type CallbackMap struct {
-	mu      sync.Mutex
-	values  map[string]Callback
-	timeNow func() time.Time
-}
-
-//
-type Callback struct {
-	ID           string
-	Value        string
-	Organization string
-	OwnerUser    User
-}
-
-//
-func (cm *CallbackMap) GenerateID() string {
-	return cm.timeNow().Format(time.RFC3339Nano)
-}
-
-//
-func (cm *CallbackMap) Set(value Callback) {
-	cm.mu.Lock()
-	defer cm.mu.Unlock()
-	cm.values[value.ID] = value
-}
-
-//
-func (cm *CallbackMap) Get(key string) (Callback, bool) {
-	cm.mu.Lock()
-	defer cm.mu.Unlock()
-	cm.cleanup()
-	value, ok := cm.values[key]
-	return value, ok
-}
-
-//
-func (cm *CallbackMap) cleanup() {
-	for key := range cm.values {
-		t, err := time.Parse(time.RFC3339Nano, key)
-		if err != nil {
-			delete(cm.values, key)
-			continue
-		}
-		if cm.timeNow().Sub(t) > time.Hour*24*7 { // 1 week
-			delete(cm.values, key)
-			continue
-		}
-	}
-}
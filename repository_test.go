@@ -0,0 +1,101 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRepositoryRefreshAdmins(t *testing.T) {
+	t.Parallel()
+	server := newTestSlackServer("U0002")
+	defer server.Close()
+
+	repository, err := NewRepository(server.SlackClient(), NewCallbackMap(0), NewInvitationMap(), NewMemberMap(), NewAuditList(), []string{"U0001"}, "S0001", nil, nil)
+	assert.NoError(t, err)
+	assert.True(t, repository.IsAdminUserID("U0001"))
+	assert.True(t, repository.IsAdminUserID("U0002"))
+	assert.False(t, repository.IsAdminUserID("U0003"))
+
+	// the members of the admin group are changed
+	server.SetGroupMembers([]string{"U0003"}, "")
+	assert.NoError(t, repository.RefreshAdmins())
+	assert.True(t, repository.IsAdminUserID("U0001"))
+	assert.False(t, repository.IsAdminUserID("U0002"))
+	assert.True(t, repository.IsAdminUserID("U0003"))
+	assert.ElementsMatch(t, []string{"name-U0001", "name-U0003"}, repository.GetAdminNames())
+}
+
+func TestRepositoryRefreshAdminsEmptyGroup(t *testing.T) {
+	t.Parallel()
+	server := newTestSlackServer("U0001")
+	defer server.Close()
+
+	repository, err := NewRepository(server.SlackClient(), NewCallbackMap(0), NewInvitationMap(), NewMemberMap(), NewAuditList(), nil, "S0001", nil, nil)
+	assert.NoError(t, err)
+	assert.True(t, repository.IsAdminUserID("U0001"))
+
+	// the current admins are kept if it fails to get the members
+	server.SetGroupMembers([]string{"U0001"}, "internal_error")
+	assert.Error(t, repository.RefreshAdmins())
+	assert.True(t, repository.IsAdminUserID("U0001"))
+
+	// the last member is removed from the admin group
+	server.SetGroupMembers([]string{}, "")
+	assert.NoError(t, repository.RefreshAdmins())
+	assert.False(t, repository.IsAdminUserID("U0001"))
+	assert.Empty(t, repository.GetAdminNames())
+}
+
+func TestRepositoryRefreshAdminsFallback(t *testing.T) {
+	t.Parallel()
+	server := newTestSlackServer("U0002")
+	defer server.Close()
+	server.SetGroupMembers(nil, "missing_scope")
+
+	// ADMIN_IDS can approve even if the admin group is not available at boot
+	repository, err := NewRepository(server.SlackClient(), NewCallbackMap(0), NewInvitationMap(), NewMemberMap(), NewAuditList(), []string{"U0001"}, "S0001", nil, nil)
+	assert.NoError(t, err)
+	assert.True(t, repository.IsAdminUserID("U0001"))
+	assert.False(t, repository.IsAdminUserID("U0002"))
+
+	_, err = NewRepository(server.SlackClient(), NewCallbackMap(0), NewInvitationMap(), NewMemberMap(), NewAuditList(), nil, "S0001", nil, nil)
+	assert.Error(t, err)
+}
+
+func TestRepositoryRecordDeletedMember(t *testing.T) {
+	t.Parallel()
+	repository := &Repository{
+		invitations: NewInvitationMap(),
+		members:     NewMemberMap(),
+	}
+	repository.invitations.Set(ApprovedInvitation{Email: "foo@example.com", ApprovedAt: time.Now()})
+	repository.members.Set(MemberRecord{Email: "foo@example.com", ScreenName: "foo"})
+
+	repository.RecordDeletedMember("foo")
+	member, ok := repository.Members().Get("foo@example.com")
+	assert.True(t, ok)
+	assert.False(t, member.Active())
+	_, ok = repository.Invitations().Get("foo@example.com")
+	assert.False(t, ok) // the deleted member cannot reuse the approval
+}
+
+func TestRepositoryRecordInvitedMember(t *testing.T) {
+	t.Parallel()
+	repository := &Repository{
+		members: NewMemberMap(),
+	}
+	requester := User{ID: "U0001", Name: "foo"}
+	repository.RecordInvitedMember("bar@example.com", "Other", "2020-09-30", requester)
+	member, ok := repository.Members().Get("bar@example.com")
+	assert.True(t, ok)
+	assert.Equal(t, "Other", member.Organization)
+	assert.Equal(t, "2020-09-30", member.ExpireDate)
+	assert.Equal(t, requester, member.InviteUser)
+
+	// the expire date is replaced by the reinvitation
+	repository.RecordInvitedMember("bar@example.com", "Other", "", requester)
+	member, _ = repository.Members().Get("bar@example.com")
+	assert.Empty(t, member.ExpireDate)
+}
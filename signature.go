@@ -0,0 +1,46 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/nlopes/slack"
+)
+
+const (
+	// maxRequestBodySize is the upper limit of the request body from slack.
+	maxRequestBodySize = 1 << 20 // 1MB
+)
+
+// VerifySignature is a middleware which verifies the request is sent from slack with the signing secret,
+// see https://api.slack.com/authentication/verifying-requests-from-slack
+func VerifySignature(signingSecret string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		buf, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxRequestBodySize))
+		if err != nil {
+			logger.Errorf("Failed to read request body: %s", err.Error())
+			w.WriteHeader(http.StatusRequestEntityTooLarge)
+			return
+		}
+		// the verifier also rejects the request whose timestamp is older than 5 minutes, to prevent replay attacks
+		verifier, err := slack.NewSecretsVerifier(r.Header, signingSecret)
+		if err != nil {
+			logger.Errorf("Invalid signature headers: %s", err.Error())
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if _, err := verifier.Write(buf); err != nil {
+			logger.Errorf("Failed to compute signature: %s", err.Error())
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		if err := verifier.Ensure(); err != nil {
+			logger.Errorf("Invalid signature: %s", err.Error())
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		r.Body = ioutil.NopCloser(bytes.NewReader(buf))
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerifySignature(t *testing.T) {
+	t.Parallel()
+	secret := "secret"
+	body := "payload=%7B%7D"
+	sign := func(secret string, timestamp int64) string {
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write([]byte("v0:" + strconv.FormatInt(timestamp, 10) + ":" + body))
+		return "v0=" + hex.EncodeToString(mac.Sum(nil))
+	}
+	now := time.Now().Unix()
+	tests := []struct {
+		timestamp    int64
+		signature    string
+		expectStatus int
+	}{
+		{
+			timestamp:    now,
+			signature:    sign(secret, now),
+			expectStatus: http.StatusOK,
+		},
+		{
+			timestamp:    now,
+			signature:    sign("invalid", now),
+			expectStatus: http.StatusUnauthorized,
+		},
+		{
+			timestamp:    now - 10*60,
+			signature:    sign(secret, now-10*60),
+			expectStatus: http.StatusUnauthorized,
+		},
+		{
+			timestamp:    now,
+			signature:    "",
+			expectStatus: http.StatusUnauthorized,
+		},
+	}
+	for _, tt := range tests {
+		t.Run("", func(t *testing.T) {
+			var received string
+			handler := VerifySignature(secret, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				buf, _ := ioutil.ReadAll(r.Body)
+				received = string(buf)
+			}))
+			req := httptest.NewRequest(http.MethodPost, "/interaction", strings.NewReader(body))
+			req.Header.Set("X-Slack-Request-Timestamp", strconv.FormatInt(tt.timestamp, 10))
+			req.Header.Set("X-Slack-Signature", tt.signature)
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			assert.Equal(t, tt.expectStatus, rec.Code)
+			if tt.expectStatus == http.StatusOK {
+				assert.Equal(t, body, received)
+			}
+		})
+	}
+}
@@ -15,15 +15,10 @@ const (
 	actionDeleteApprove            = "deleteApprove"
 	actionCleanupConfirm           = "cleanupConfirm"
 	actionCleanupApprove           = "cleanupApprove"
+	actionRevokeConfirm            = "revokeConfirm"
+	actionRevokeApprove            = "revokeApprove"
 	actionCancel                   = "cancel"
 	actionReject                   = "reject"
-
-	// color codes
-	ColorCodeRed    = "#FF0000"
-	ColorCodeOrange = "#FFA500"
-	ColorCodeYellow = "#FFFF00"
-	ColorCodeGreen  = "#00FF00"
-	ColorCodeBlue   = "#0000FF"
 )
 
 // WrapTextInCodeBlock wraps a string into a code-block formatted string
@@ -51,6 +46,11 @@ func WrapUserGroupIDInLink(userGroupID string) string {
 	return fmt.Sprintf("<!subteam^%s>", userGroupID)
 }
 
+// WrapChannelIDInLink converts to a linkable channel
+func WrapChannelIDInLink(channelID string) string {
+	return fmt.Sprintf("<#%s>", channelID)
+}
+
 // WrapTextInLink converts to a linkable string
 func WrapTextInLink(des, link string) string {
 	return fmt.Sprintf("<%s|%s>", link, des)
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+
+	"github.com/nlopes/slack"
+)
+
+// testSlackServer is a fake slack api server, which resolves any user and accepts the messages.
+type testSlackServer struct {
+	*httptest.Server
+	mu           sync.Mutex
+	groupMembers []string
+	groupError   string
+}
+
+//
+func newTestSlackServer(groupMembers ...string) *testSlackServer {
+	s := &testSlackServer{groupMembers: groupMembers}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+// SlackClient returns the slack client which calls the fake server.
+func (s *testSlackServer) SlackClient() *slack.Client {
+	return slack.New("token", slack.OptionAPIURL(s.URL+"/"))
+}
+
+// SetGroupMembers changes the members of the user group, or makes it fail with the error if specified.
+func (s *testSlackServer) SetGroupMembers(members []string, errorCode string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.groupMembers = members
+	s.groupError = errorCode
+}
+
+//
+func (s *testSlackServer) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	w.Header().Set("Content-Type", "application/json")
+	switch r.URL.Path {
+	case "/usergroups.users.list":
+		if s.groupError != "" {
+			json.NewEncoder(w).Encode(map[string]interface{}{"ok": false, "error": s.groupError})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"ok": true, "users": s.groupMembers})
+	case "/users.info":
+		id := r.FormValue("user")
+		json.NewEncoder(w).Encode(map[string]interface{}{"ok": true, "user": map[string]interface{}{"id": id, "name": "name-" + id}})
+	case "/chat.update", "/chat.postEphemeral":
+		json.NewEncoder(w).Encode(map[string]interface{}{"ok": true, "channel": r.FormValue("channel"), "ts": "1.0"})
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
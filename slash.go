@@ -0,0 +1,68 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/nlopes/slack"
+)
+
+// SlashCommandHandler handles the slash command requests, and dispatches the commands to the MessageListener.
+type SlashCommandHandler struct {
+	listener *MessageListener
+	command  string
+}
+
+//
+func NewSlashCommandHandler(listener *MessageListener, command string) *SlashCommandHandler {
+	return &SlashCommandHandler{
+		listener: listener,
+		command:  command,
+	}
+}
+
+//
+func (h *SlashCommandHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		logger.Errorf("Invalid method: %s", r.Method)
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, maxRequestBodySize)
+	sc, err := slack.SlashCommandParse(r)
+	if err != nil {
+		logger.Errorf("Failed to parse slash command: %s", err.Error())
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if sc.Command != h.command {
+		logger.Errorf("Invalid command: %s", sc.Command)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if sc.UserID == "" || sc.ResponseURL == "" {
+		logger.Errorf("Invalid slash command: user=%s, response_url=%s", sc.UserID, sc.ResponseURL)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	cmd := parseSlashCommand(sc)
+
+	// slack requires the response within 3 seconds, so it replies to the command via the response url asynchronously
+	w.WriteHeader(http.StatusOK)
+	go h.listener.execute(cmd)
+}
+
+// parseSlashCommand converts the slash command to the command, the first word of the text is the name of the command.
+func parseSlashCommand(sc slack.SlashCommand) Command {
+	cmd := Command{
+		Prefix:      sc.Command,
+		UserID:      sc.UserID,
+		ChannelID:   sc.ChannelID,
+		ResponseURL: sc.ResponseURL,
+	}
+	if fields := strings.Fields(sc.Text); len(fields) >= 1 {
+		cmd.Name = fields[0]
+		cmd.Args = fields[1:]
+	}
+	return cmd
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/nlopes/slack"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSlashCommandHandlerServeHTTP(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name         string
+		method       string
+		form         url.Values
+		expectStatus int
+	}{
+		{
+			name:         "invalid method",
+			method:       http.MethodGet,
+			form:         url.Values{},
+			expectStatus: http.StatusMethodNotAllowed,
+		},
+		{
+			name:   "command mismatch",
+			method: http.MethodPost,
+			form: url.Values{
+				"command":      {"/other"},
+				"user_id":      {"U0001"},
+				"response_url": {"https://hooks.slack.com/commands/T0001/0001/xxxx"},
+				"text":         {"help"},
+			},
+			expectStatus: http.StatusBadRequest,
+		},
+		{
+			name:   "missing response url",
+			method: http.MethodPost,
+			form: url.Values{
+				"command": {"/esa"},
+				"user_id": {"U0001"},
+				"text":    {"help"},
+			},
+			expectStatus: http.StatusBadRequest,
+		},
+		{
+			name:   "missing user",
+			method: http.MethodPost,
+			form: url.Values{
+				"command":      {"/esa"},
+				"response_url": {"https://hooks.slack.com/commands/T0001/0001/xxxx"},
+				"text":         {"help"},
+			},
+			expectStatus: http.StatusBadRequest,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			// the listener is not set, since the invalid commands must not be dispatched
+			handler := NewSlashCommandHandler(nil, "/esa")
+			req := httptest.NewRequest(tt.method, "/slack/commands", strings.NewReader(tt.form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			assert.Equal(t, tt.expectStatus, rec.Code)
+		})
+	}
+}
+
+func TestParseSlashCommand(t *testing.T) {
+	t.Parallel()
+	sc := slack.SlashCommand{
+		Command:     "/esa",
+		UserID:      "U0001",
+		ChannelID:   "C0001",
+		ResponseURL: "https://hooks.slack.com/commands/T0001/0001/xxxx",
+	}
+	tests := []struct {
+		text       string
+		expectName string
+		expectArgs []string
+	}{
+		{text: "", expectName: "", expectArgs: nil},
+		{text: "help", expectName: "help", expectArgs: []string{}},
+		{text: "invite foo@example.com", expectName: "invite", expectArgs: []string{"foo@example.com"}},
+		{text: "  cleanup   --dry-run  6 ", expectName: "cleanup", expectArgs: []string{"--dry-run", "6"}},
+	}
+	for _, tt := range tests {
+		sc.Text = tt.text
+		cmd := parseSlashCommand(sc)
+		assert.Equal(t, Command{
+			Name:        tt.expectName,
+			Args:        tt.expectArgs,
+			Prefix:      "/esa",
+			UserID:      "U0001",
+			ChannelID:   "C0001",
+			ResponseURL: "https://hooks.slack.com/commands/T0001/0001/xxxx",
+		}, cmd, tt.text)
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/nlopes/slack"
+)
+
+// CallbackSweeper removes the expired requests periodically,
+// and updates their messages to not leave the stale buttons in the channel.
+type CallbackSweeper struct {
+	ctx         context.Context
+	slackClient *slack.Client
+	repository  *Repository
+	interval    time.Duration
+}
+
+//
+func (s *CallbackSweeper) Run() {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		s.sweep()
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+//
+func (s *CallbackSweeper) sweep() {
+	callbacks, err := s.repository.Callbacks().Sweep()
+	if err != nil {
+		logger.Errorf("Failed to sweep expired requests: %s", err.Error())
+	}
+	for _, cb := range callbacks {
+		logger.Infof("Request %s has expired", cb.ID)
+		s.repository.Audit(auditEventExpired, cb, User{}, "")
+		if cb.ChannelID == "" || cb.MessageTs == "" {
+			continue
+		}
+		if err := s.updateExpiredMessage(cb); err != nil {
+			logger.Errorf("Failed to update expired request message %s: %s", cb.ID, err.Error())
+		}
+	}
+}
+
+//
+func (s *CallbackSweeper) updateExpiredMessage(cb Callback) error {
+	setLastStage(cb.Stages, StageStatusError, "")
+	cb.Stages = addNoteToLastStage(cb.Stages, ":hourglass: Request has expired: "+cb.ShortID())
+	if _, _, _, err := s.slackClient.UpdateMessage(cb.ChannelID, cb.MessageTs, stageMessageOptions(cb.ID, cb.Stages)...); err != nil {
+		return fmt.Errorf("failed to update message: %s", err)
+	}
+	return nil
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/nlopes/slack"
+)
+
+const (
+	defaultSlackEndpoint = "https://slack.com/api/"
+
+	// interaction types which are not supported by the slack library yet
+	interactionTypeShortcut       = slack.InteractionType("shortcut")
+	interactionTypeViewSubmission = slack.InteractionType("view_submission")
+
+	// block types which are not supported by the slack library yet
+	blockTypeInput = slack.MessageBlockType("input")
+)
+
+// ViewClient calls the views api of slack, which is not supported by the slack library yet.
+type ViewClient struct {
+	endpoint string
+	token    string
+	http     *http.Client
+}
+
+//
+func NewViewClient(token string) *ViewClient {
+	return &ViewClient{
+		endpoint: defaultSlackEndpoint,
+		token:    token,
+		http:     &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// ModalView is the modal view to open by views.open.
+type ModalView struct {
+	Type            string                 `json:"type"`
+	CallbackID      string                 `json:"callback_id"`
+	Title           *slack.TextBlockObject `json:"title"`
+	Submit          *slack.TextBlockObject `json:"submit,omitempty"`
+	Close           *slack.TextBlockObject `json:"close,omitempty"`
+	Blocks          []slack.Block          `json:"blocks"`
+	PrivateMetadata string                 `json:"private_metadata,omitempty"`
+}
+
+// InputBlock is the block to collect the user input in the modal view.
+type InputBlock struct {
+	Type     slack.MessageBlockType `json:"type"`
+	BlockID  string                 `json:"block_id"`
+	Label    *slack.TextBlockObject `json:"label"`
+	Element  interface{}            `json:"element"`
+	Hint     *slack.TextBlockObject `json:"hint,omitempty"`
+	Optional bool                   `json:"optional,omitempty"`
+}
+
+//
+func (b InputBlock) BlockType() slack.MessageBlockType {
+	return b.Type
+}
+
+// PlainTextInputElement is the element of the input block to enter the text.
+type PlainTextInputElement struct {
+	Type        string                 `json:"type"`
+	ActionID    string                 `json:"action_id"`
+	Placeholder *slack.TextBlockObject `json:"placeholder,omitempty"`
+	Multiline   bool                   `json:"multiline,omitempty"`
+}
+
+//
+func NewPlainTextInputElement(actionID string, placeholder string, multiline bool) *PlainTextInputElement {
+	return &PlainTextInputElement{
+		Type:        "plain_text_input",
+		ActionID:    actionID,
+		Placeholder: slack.NewTextBlockObject(slack.PlainTextType, placeholder, false, false),
+		Multiline:   multiline,
+	}
+}
+
+// ViewSubmission is the payload of view_submission, which is not supported by the slack library yet.
+type ViewSubmission struct {
+	Type string     `json:"type"`
+	User slack.User `json:"user"`
+	View struct {
+		ID         string `json:"id"`
+		CallbackID string `json:"callback_id"`
+		State      struct {
+			Values map[string]map[string]ViewStateValue `json:"values"`
+		} `json:"state"`
+	} `json:"view"`
+}
+
+// ViewStateValue is the value of the input element in the submitted view.
+type ViewStateValue struct {
+	Type           string                  `json:"type"`
+	Value          string                  `json:"value"`
+	SelectedDate   string                  `json:"selected_date"`
+	SelectedOption slack.OptionBlockObject `json:"selected_option"`
+}
+
+// Value returns the value of the input element specified by the block id and the action id.
+func (v ViewSubmission) Value(blockID, actionID string) ViewStateValue {
+	return v.View.State.Values[blockID][actionID]
+}
+
+// OpenView opens the modal view for the user who triggered the interaction.
+func (c *ViewClient) OpenView(ctx context.Context, triggerID string, view ModalView) error {
+	body, err := json.Marshal(struct {
+		TriggerID string    `json:"trigger_id"`
+		View      ModalView `json:"view"`
+	}{triggerID, view})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, c.endpoint+"views.open", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	res, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	data, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to open view: status=%d", res.StatusCode)
+	}
+	var ret slack.SlackResponse
+	if err := json.Unmarshal(data, &ret); err != nil {
+		return err
+	}
+	if !ret.Ok {
+		return fmt.Errorf("failed to open view: %s", ret.Error)
+	}
+	return nil
+}